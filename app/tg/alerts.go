@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"hack-a-tone/internal/core/domain"
+	"hack-a-tone/internal/core/utils"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxReplicas = 30
+
+var PodsThatWas sync.Map
+
+// IngestAlert записывает алерт из очереди в хранилище. Возвращает ID алерта, если о нем нужно
+// уведомить чаты, и 0 для повторов и заглушенных алертов
+func (b *Bot) IngestAlert(a domain.Alert) (int64, error) {
+	ns := a.Labels.Namespace
+	if ns == "" {
+		var err error
+		ns, err = b.k8sController.GetNamespaceFromPod(context.Background(), a.Labels.Pod)
+		if err != nil {
+			slog.Error("getting namespace from pod name", "error", err)
+			if was, ok := PodsThatWas.Load(a.Labels.Pod); ok {
+				ns = was.(string)
+			}
+		} else {
+			PodsThatWas.Store(a.Labels.Pod, ns)
+		}
+	}
+	a.Namespace = ns
+
+	if a.Fingerprint == "" {
+		a.Fingerprint = a.Labels.Fingerprint()
+	}
+
+	prev, err := b.repo.GetOpenAlert(a.Fingerprint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find open alert %s: %w", a.Fingerprint, err)
+	}
+
+	if prev != nil {
+		a.ID = prev.ID
+		a.Deployment = prev.Deployment
+		a.AckedBy, a.AckedByName, a.AckedAt = prev.AckedBy, prev.AckedByName, prev.AckedAt
+		a.Suppressed = prev.Suppressed
+		if a.StartsAt.IsZero() {
+			a.StartsAt = prev.StartsAt
+		}
+		if err = b.repo.UpdateAlert(a); err != nil {
+			return 0, fmt.Errorf("failed to update alert %d: %w", a.ID, err)
+		}
+
+		if !a.IsResolved() {
+			slog.Info("Повторный алерт, уведомление не отправляется", "fingerprint", a.Fingerprint, "id", a.ID)
+			return 0, nil
+		}
+		return a.ID, nil
+	}
+
+	if a.StartsAt.IsZero() {
+		a.StartsAt = time.Now()
+	}
+	a.Deployment = b.resolveDeployment(a)
+	silence, silenced := domain.FindSilence(b.activeSilences(time.Now()), a, time.Now())
+	a.Suppressed = silenced
+	if !silenced && b.grouping.Enabled() {
+		a.GroupKey = b.grouping.Key(a)
+		a.ID, _, err = b.repo.WriteGroupedAlert(a, ns, b.grouping.Window, time.Now())
+	} else {
+		a.ID, err = b.repo.WriteAlert(a, ns)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write alert: %w", err)
+	}
+
+	if silenced {
+		slog.Info("Алерт заглушен", "id", a.ID, "alertname", a.Labels.Alertname, "silenceID", silence.ID)
+		return 0, nil
+	}
+	return a.ID, nil
+}
+
+// NotifyAlert рассылает уведомление об алерте подписанным чатам. Разрешенный алерт и новые алерты
+// существующей группы обновляют уже отправленные сообщения. Чаты, где сообщение уже есть,
+// повторно не получают его, поэтому вызов можно повторять после ошибки
+func (b *Bot) NotifyAlert(alertID int64) error {
+	a, err := b.repo.GetAlert(alertID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert %d: %w", alertID, err)
+	}
+	if a == nil || a.Suppressed {
+		return nil
+	}
+	if leaderID := a.GroupLeaderID(); leaderID != 0 {
+		return b.notifyGroup(leaderID)
+	}
+
+	msgs, err := b.repo.GetAlertMessages(a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert %d messages: %w", a.ID, err)
+	}
+	if a.IsResolved() && len(msgs) > 0 {
+		return b.refreshAlertMessages(*a)
+	}
+	return b.deliverAlert(*a, msgs)
+}
+
+// deliverAlert отправляет сообщение об алерте в подписанные чаты, где его еще нет
+func (b *Bot) deliverAlert(a domain.Alert, msgs []domain.AlertMessage) error {
+	sent := make(map[int64]bool, len(msgs))
+	for _, m := range msgs {
+		sent[m.ChatID] = true
+	}
+
+	var errs []error
+	for _, chatID := range b.subs.ChatIDs(a.Namespace) {
+		if sent[chatID] {
+			continue
+		}
+		if err := b.sendAlertMessage(chatID, a); err != nil && retryableSendError(err) {
+			errs = append(errs, fmt.Errorf("chat %d: %w", chatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendAlertMessage отправляет сообщение об алерте с кнопками действий и запоминает его,
+// чтобы потом обновлять при подтверждении и разрешении
+func (b *Bot) sendAlertMessage(chatID int64, a domain.Alert) error {
+	text, keyboard, ok := b.renderAlertMessage(a, "")
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if ok {
+		msg.ReplyMarkup = keyboard
+	}
+	sent, coalesced, err := b.sender.SendAlert(msg)
+	if err != nil {
+		slog.Error("Не удалось отправить алерт", "chatID", chatID, "error", err)
+		return err
+	}
+	// В объединенном сообщении несколько алертов, обновлять его по одному алерту нельзя
+	if a.ID == 0 || coalesced {
+		return nil
+	}
+
+	err = b.repo.AddAlertMessage(domain.AlertMessage{AlertID: a.ID, ChatID: chatID, MessageID: sent.MessageID})
+	if err != nil {
+		slog.Error("Не удалось сохранить сообщение алерта", "id", a.ID, "chatID", chatID, "error", err)
+	}
+	return nil
+}
+
+// refreshAlertMessages перерисовывает все ранее отправленные сообщения об алерте.
+// Сообщения группы принадлежат ее первому алерту
+func (b *Bot) refreshAlertMessages(a domain.Alert) error {
+	if leaderID := a.GroupLeaderID(); leaderID != 0 && leaderID != a.ID {
+		leader, err := b.repo.GetAlert(leaderID)
+		if err != nil {
+			return fmt.Errorf("failed to get alert group %d: %w", leaderID, err)
+		}
+		if leader == nil {
+			return nil
+		}
+		a = *leader
+	}
+
+	msgs, err := b.repo.GetAlertMessages(a.ID)
+	if err != nil {
+		slog.Error("Не удалось получить сообщения алерта", "id", a.ID, "error", err)
+		return err
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	text, keyboard, hasKeyboard := b.renderAlertMessage(a, "")
+	var errs []error
+	for _, m := range msgs {
+		edit := tgbotapi.NewEditMessageText(m.ChatID, m.MessageID, text)
+		edit.ParseMode = tgbotapi.ModeHTML
+		if hasKeyboard {
+			edit.ReplyMarkup = &keyboard
+		}
+		if _, err = b.sender.Send(edit); err != nil && !isNotModified(err) {
+			slog.Error("Не удалось обновить сообщение алерта", "chatID", m.ChatID, "messageID", m.MessageID, "error", err)
+			if retryableSendError(err) {
+				errs = append(errs, fmt.Errorf("chat %d message %d: %w", m.ChatID, m.MessageID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retryableSendError отличает временные ошибки Telegram от тех, которые повтор не исправит:
+// чат не найден, бот заблокирован, некорректный запрос
+func retryableSendError(err error) bool {
+	var tgErr tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		if tgErr.RetryAfter > 0 {
+			return true
+		}
+		return !strings.HasPrefix(tgErr.Message, "Bad Request") && !strings.HasPrefix(tgErr.Message, "Forbidden")
+	}
+	return true
+}
+
+// isNotModified Telegram отвечает ошибкой, если текст сообщения не изменился
+func isNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
+
+// resolveDeployment находит deployment, которому принадлежит под из алерта
+func (b *Bot) resolveDeployment(a domain.Alert) string {
+	if depl := a.Labels.Get("deployment"); depl != "" {
+		return depl
+	}
+	if a.Labels.Pod == "" || a.Namespace == "" {
+		return ""
+	}
+
+	ctx := context.Background()
+	pod, err := b.k8sController.GetPod(ctx, a.Namespace, a.Labels.Pod)
+	if err != nil {
+		slog.Error("Не удалось получить под из алерта", "pod", a.Labels.Pod, "namespace", a.Namespace, "error", err)
+		return ""
+	}
+	depl, err := b.k8sController.GetDeploymentFromPod(ctx, pod)
+	if err != nil {
+		slog.Error("Не удалось определить deployment пода", "pod", a.Labels.Pod, "error", err)
+		return ""
+	}
+
+	return depl
+}
+
+// alertKeyboard собирает кнопки действий для сообщения об активном алерте
+func alertKeyboard(a domain.Alert) (tgbotapi.InlineKeyboardMarkup, bool) {
+	if a.ID == 0 || a.IsResolved() || a.Namespace == "" {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	return actionKeyboard(a.ID, a.IsAcked(), a.Labels.Pod, a.Deployment), true
+}
+
+// actionKeyboard кнопки действий над алертом alertID. Без пода и deployment соответствующих кнопок нет
+func actionKeyboard(alertID int64, acked bool, pod, deployment string) tgbotapi.InlineKeyboardMarkup {
+	button := func(text, key string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, mustJSON(ActionData{Key: key, Alert: alertID}))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if !acked {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button("👀 Взять в работу", "al_ack")))
+	}
+	if pod != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button("🔁 Перезапустить под", "al_pod")))
+	}
+	if deployment != "" {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				button("🔄 Перезапустить deployment", "al_dep"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				button("🔙 Откатить", "al_roll"),
+				button("⬆️ +1 под", "al_up"),
+			),
+		)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(button("🔕 Заглушить на 1ч", "al_sil")))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// alertAction оборачивает действие над алертом: достает алерт из хранилища и сообщает результат в чат
+func (b *Bot) alertAction(action func(actor Actor, a domain.Alert) (string, error)) func(*tgbotapi.CallbackQuery) {
+	return func(cq *tgbotapi.CallbackQuery) {
+		var data ActionData
+		json.Unmarshal([]byte(cq.Data), &data)
+
+		a, err := b.repo.GetAlert(data.Alert)
+		if err != nil || a == nil {
+			b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Алерт не найден"))
+			return
+		}
+
+		actor := callbackActor(cq)
+		result, err := action(actor, *a)
+		if err != nil {
+			slog.Error("Действие по алерту не выполнено", "alertID", a.ID, "key", data.Key, "error", err)
+			b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Не получилось: "+err.Error()))
+			b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("%s: не получилось — %s ❌", actor.UserName, err), actionButtons)
+			return
+		}
+
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, result))
+		b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("%s: %s ✅", actor.UserName, result), actionButtons)
+	}
+}
+
+// ackAlert отмечает, кто взял алерт в работу, и обновляет сообщения о нем во всех чатах
+func (b *Bot) ackAlert(cq *tgbotapi.CallbackQuery) {
+	var data ActionData
+	json.Unmarshal([]byte(cq.Data), &data)
+
+	a, err := b.repo.GetAlert(data.Alert)
+	if err != nil || a == nil {
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Алерт не найден"))
+		return
+	}
+	if a.GroupLeaderID() != 0 {
+		b.ackGroup(cq, *a)
+		return
+	}
+	if a.IsAcked() {
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, "Уже в работе у "+a.AckedByName))
+		return
+	}
+
+	actor := callbackActor(cq)
+	if err = b.repo.AckAlert(a.ID, actor.UserID, actor.UserName, time.Now()); err != nil {
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Не удалось подтвердить алерт"))
+		return
+	}
+	slog.Info("Алерт подтвержден", "alertID", a.ID, "user", actor.UserID)
+
+	if updated, err := b.repo.GetAlert(a.ID); err == nil && updated != nil {
+		b.refreshAlertMessages(*updated)
+	}
+	b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, "Алерт взят в работу"))
+}
+
+// confirmAlertAction спрашивает подтверждение действия над алертом кнопками ✅/❌, как при откате
+// и перезапуске из меню. ask возвращает вопрос и данные кнопки ✅
+func (b *Bot) confirmAlertAction(ask func(a domain.Alert) (string, ActionData, error)) func(*tgbotapi.CallbackQuery) {
+	return func(cq *tgbotapi.CallbackQuery) {
+		var data ActionData
+		json.Unmarshal([]byte(cq.Data), &data)
+
+		a, err := b.repo.GetAlert(data.Alert)
+		if err != nil || a == nil {
+			b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Алерт не найден"))
+			return
+		}
+
+		question, yes, err := ask(*a)
+		if err != nil {
+			slog.Error("Не удалось подготовить действие по алерту", "alertID", a.ID, "key", data.Key, "error", err)
+			b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Не получилось: "+err.Error()))
+			return
+		}
+		yes.Alert = a.ID
+		checkBtn := tgbotapi.NewInlineKeyboardButtonData("✅", mustJSON(yes))
+		crossBtn := tgbotapi.NewInlineKeyboardButtonData("❌", mustJSON(ActionData{Key: "al_no", Alert: a.ID}))
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(checkBtn, crossBtn))
+
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, ""))
+		b.MessageWithReplyMarkup(cq.Message.Chat.ID, question, keyboard)
+	}
+}
+
+// confirmedAlertAction выполняет подтвержденное действие и заменяет вопрос результатом,
+// убирая кнопки, чтобы действие нельзя было повторить тем же сообщением
+func (b *Bot) confirmedAlertAction(action func(actor Actor, a domain.Alert, data ActionData) (string, error)) func(*tgbotapi.CallbackQuery) {
+	return func(cq *tgbotapi.CallbackQuery) {
+		var data ActionData
+		json.Unmarshal([]byte(cq.Data), &data)
+
+		a, err := b.repo.GetAlert(data.Alert)
+		if err != nil || a == nil {
+			b.finishCallback(cq, "Алерт не найден ❌")
+			return
+		}
+
+		actor := callbackActor(cq)
+		result, err := action(actor, *a, data)
+		if err != nil {
+			slog.Error("Действие по алерту не выполнено", "alertID", a.ID, "key", data.Key, "error", err)
+			b.finishCallback(cq, fmt.Sprintf("%s: не получилось — %s ❌", actor.UserName, err))
+			return
+		}
+		b.finishCallback(cq, fmt.Sprintf("%s: %s ✅", actor.UserName, result))
+	}
+}
+
+func (b *Bot) askRestartPod(a domain.Alert) (string, ActionData, error) {
+	return fmt.Sprintf("Перезапустить под %s?", a.Labels.Pod), ActionData{Key: "al_pod_yes"}, nil
+}
+
+func (b *Bot) alertRestartPod(actor Actor, a domain.Alert, _ ActionData) (string, error) {
+	err := b.k8sController.RestartPod(context.Background(), a.Namespace, a.Labels.Pod)
+	b.audit(actor, domain.ActionRestartPod, a.Namespace, a.Deployment, "pod="+a.Labels.Pod, err)
+	return fmt.Sprintf("под %s перезапущен", a.Labels.Pod), err
+}
+
+func (b *Bot) askRestartDeployment(a domain.Alert) (string, ActionData, error) {
+	return fmt.Sprintf("Перезапустить deployment %s?", a.Deployment), ActionData{Key: "al_dep_yes"}, nil
+}
+
+func (b *Bot) alertRestartDeployment(actor Actor, a domain.Alert, _ ActionData) (string, error) {
+	err := b.k8sController.RestartDeployment(context.Background(), a.Deployment, a.Namespace)
+	b.audit(actor, domain.ActionRestartDeployment, a.Namespace, a.Deployment, "", err)
+	return fmt.Sprintf("deployment %s перезапущен", a.Deployment), err
+}
+
+// askRollback выбирает ревизию сразу, чтобы подтверждение откатывало именно на нее
+func (b *Bot) askRollback(a domain.Alert) (string, ActionData, error) {
+	revision, err := b.previousRevision(a.Namespace, a.Deployment)
+	if err != nil {
+		return "", ActionData{}, err
+	}
+	question := fmt.Sprintf("Откатить deployment %s на ревизию %s?", a.Deployment, revision)
+	return question, ActionData{Key: "al_roll_yes", Revision: revision}, nil
+}
+
+func (b *Bot) alertRollback(actor Actor, a domain.Alert, data ActionData) (string, error) {
+	if data.Revision == "" {
+		return "", errors.New("не указана ревизия")
+	}
+	err := b.k8sController.SetRevision(context.Background(), a.Deployment, a.Namespace, data.Revision)
+	b.audit(actor, domain.ActionRollback, a.Namespace, a.Deployment, "revision="+data.Revision, err)
+	return fmt.Sprintf("deployment %s откачен на ревизию %s", a.Deployment, data.Revision), err
+}
+
+// askScaleUp запоминает текущее количество подов в кнопке: повторное подтверждение
+// не добавит еще один под
+func (b *Bot) askScaleUp(a domain.Alert) (string, ActionData, error) {
+	current, err := b.deploymentReplicas(a.Namespace, a.Deployment)
+	if err != nil {
+		return "", ActionData{}, err
+	}
+	if current >= maxReplicas {
+		return "", ActionData{}, fmt.Errorf("уже максимальное количество подов (%d)", maxReplicas)
+	}
+	question := fmt.Sprintf("Увеличить количество подов %s с %d до %d?", a.Deployment, current, current+1)
+	return question, ActionData{Key: "al_up_yes", Replicas: current}, nil
+}
+
+func (b *Bot) alertScaleUp(actor Actor, a domain.Alert, data ActionData) (string, error) {
+	current, err := b.deploymentReplicas(a.Namespace, a.Deployment)
+	if err != nil {
+		return "", err
+	}
+	if current != data.Replicas {
+		return "", fmt.Errorf("количество подов уже изменилось: сейчас %d", current)
+	}
+	if current >= maxReplicas {
+		return "", fmt.Errorf("уже максимальное количество подов (%d)", maxReplicas)
+	}
+
+	err = b.k8sController.ScalePod(context.Background(), a.Deployment, a.Namespace, current+1)
+	b.audit(actor, domain.ActionScale, a.Namespace, a.Deployment, fmt.Sprintf("replicas=%d->%d", current, current+1), err)
+	return fmt.Sprintf("количество подов %s увеличено до %d", a.Deployment, current+1), err
+}
+
+// deploymentReplicas возвращает желаемое количество подов deployment
+func (b *Bot) deploymentReplicas(ns, depl string) (int32, error) {
+	deployments, err := b.k8sController.GetDeployments(context.Background(), ns)
+	if err != nil {
+		return 0, err
+	}
+	for i := range deployments.Items {
+		if d := &deployments.Items[i]; d.Name == depl {
+			return utils.GetReplicasCountForDeployment(d), nil
+		}
+	}
+	return 0, fmt.Errorf("deployment %s не найден", depl)
+}
+
+// previousRevision возвращает ревизию, предшествующую текущей
+func (b *Bot) previousRevision(ns, depl string) (string, error) {
+	revs, err := b.k8sController.GetAvailableRevisions(context.Background(), depl, ns)
+	if err != nil {
+		return "", err
+	}
+
+	nums := make([]int, 0, len(revs))
+	for _, r := range revs {
+		if n, err := strconv.Atoi(r); err == nil {
+			nums = append(nums, n)
+		}
+	}
+	if len(nums) < 2 {
+		return "", errors.New("нет предыдущей ревизии")
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(nums)))
+	return strconv.Itoa(nums[1]), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	auditPageSize = 20
+	auditMaxSize  = auditPageSize * 5
+)
+
+// audit записывает в журнал действие над кластером и его результат
+func (b *Bot) audit(actor Actor, action, namespace, deployment, params string, actionErr error) {
+	entry := domain.AuditEntry{
+		UserID:     actor.UserID,
+		UserName:   actor.UserName,
+		ChatID:     actor.ChatID,
+		Action:     action,
+		Namespace:  namespace,
+		Deployment: deployment,
+		Params:     params,
+		Result:     domain.AuditResultOK,
+	}
+	if actionErr != nil {
+		entry.Result = domain.AuditResultError
+		entry.Error = actionErr.Error()
+	}
+
+	slog.Info("Audit", "user", actor.UserID, "chatID", actor.ChatID, "action", action,
+		"namespace", namespace, "deployment", deployment, "params", params, "result", entry.Result)
+	if err := b.auditLog.WriteAudit(entry); err != nil {
+		slog.Error("Не удалось сохранить запись журнала", "action", action, "error", err)
+	}
+}
+
+// showAudit показывает последние действия в namespace: /audit [namespace] [n], n не больше auditMaxSize
+func (b *Bot) showAudit(actor Actor, args []string) {
+	n := auditPageSize
+	if len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed <= 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Использование: /audit [namespace] [количество]", actionButtons)
+			return
+		}
+		n = min(parsed, auditMaxSize)
+	}
+
+	show := func(ns string) {
+		entries, err := b.auditLog.GetLastNAudit(n, ns)
+		if err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось получить журнал действий", actionButtons)
+			return
+		}
+		if len(entries) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("В журнале нет действий для namespace %s", ns), actionButtons)
+			return
+		}
+
+		out := make([]string, len(entries))
+		for i, e := range entries {
+			out[i] = e.String()
+		}
+		text := fmt.Sprintf("Журнал действий %s:\n%s", ns, strings.Join(out, "\n"))
+		b.MessageWithReplyMarkup(actor.ChatID, truncateText(text, maxMessageLen), actionButtons)
+	}
+
+	if len(args) == 0 {
+		b.AskNamespace(actor, show)
+		return
+	}
+	if !slices.Contains(b.subs.Namespaces(actor.ChatID), args[0]) {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+args[0], actionButtons)
+		return
+	}
+	show(args[0])
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Actor описывает, кто и из какого чата выполняет действие
+type Actor struct {
+	ChatID   int64
+	UserID   int64
+	UserName string
+}
+
+func messageActor(m *tgbotapi.Message) Actor {
+	return Actor{ChatID: m.Chat.ID, UserID: userID(m.From), UserName: userName(m.From)}
+}
+
+func callbackActor(cq *tgbotapi.CallbackQuery) Actor {
+	return Actor{ChatID: cq.Message.Chat.ID, UserID: userID(cq.From), UserName: userName(cq.From)}
+}
+
+func userID(u *tgbotapi.User) int64 {
+	if u == nil {
+		return 0
+	}
+	return int64(u.ID)
+}
+
+func userName(u *tgbotapi.User) string {
+	if u == nil {
+		return ""
+	}
+	if u.UserName != "" {
+		return "@" + u.UserName
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// Роли, необходимые для кнопок основного меню
+var buttonRoles = map[string]domain.Role{
+	ViewData:          domain.RoleViewer,
+	SeeLastIncidents:  domain.RoleViewer,
+	ChangePods:        domain.RoleOperator,
+	RestartDeployment: domain.RoleOperator,
+	RestartPod:        domain.RoleOperator,
+	RollbackVersion:   domain.RoleOperator,
+}
+
+// Роли, необходимые для команд. Команды, которых здесь нет, доступны всем
+var commandRoles = map[string]domain.Role{
+	"start":           domain.RoleOperator,
+	"subscribe":       domain.RoleOperator,
+	"unsubscribe":     domain.RoleOperator,
+	"namespaces":      domain.RoleViewer,
+	"audit":           domain.RoleOperator,
+	"incidents":       domain.RoleViewer,
+	"stats":           domain.RoleViewer,
+	"export":          domain.RoleViewer,
+	"digest":          domain.RoleOperator,
+	"escalation":      domain.RoleAdmin,
+	"oncall":          domain.RoleViewer,
+	"oncall_set":      domain.RoleAdmin,
+	"oncall_override": domain.RoleOperator,
+	"silence":         domain.RoleOperator,
+	"silences":        domain.RoleViewer,
+	"unsilence":       domain.RoleOperator,
+	"users":           domain.RoleAdmin,
+	"grant":           domain.RoleAdmin,
+	"revoke":          domain.RoleAdmin,
+}
+
+// Роли, необходимые для inline-кнопок
+var callbackRoles = map[string]domain.Role{
+	"roll_yes":    domain.RoleOperator,
+	"roll_no":     domain.RoleOperator,
+	"rs_yes":      domain.RoleOperator,
+	"rs_no":       domain.RoleOperator,
+	"al_ack":      domain.RoleOperator,
+	"al_pod":      domain.RoleOperator,
+	"al_pod_yes":  domain.RoleOperator,
+	"al_dep":      domain.RoleOperator,
+	"al_dep_yes":  domain.RoleOperator,
+	"al_roll":     domain.RoleOperator,
+	"al_roll_yes": domain.RoleOperator,
+	"al_up":       domain.RoleOperator,
+	"al_up_yes":   domain.RoleOperator,
+	"al_no":       domain.RoleOperator,
+	"al_sil":      domain.RoleOperator,
+}
+
+// authorize проверяет, что у пользователя есть нужная роль. Если прав не хватает,
+// возвращает false и текст, который стоит показать пользователю.
+func (b *Bot) authorize(actor Actor, need domain.Role) (bool, string) {
+	if need == domain.RoleNone {
+		return true, ""
+	}
+
+	user, err := b.users.GetUser(actor.UserID)
+	if err != nil {
+		slog.Error("Не удалось проверить права пользователя", "userID", actor.UserID, "error", err)
+		return false, "Не удалось проверить права доступа"
+	}
+
+	if user == nil || !user.Can(need) {
+		slog.Warn("Доступ запрещен", "userID", actor.UserID, "user", actor.UserName, "chatID", actor.ChatID, "need", need.String())
+		return false, fmt.Sprintf("Недостаточно прав: нужна роль %s. Ваш Telegram ID: %d", need, actor.UserID)
+	}
+
+	if actor.UserName != "" && user.Name != actor.UserName {
+		user.Name = actor.UserName
+		if err = b.users.SaveUser(*user); err != nil {
+			slog.Error("Не удалось обновить имя пользователя", "userID", actor.UserID, "error", err)
+		}
+	}
+
+	return true, ""
+}
+
+// allowed проверяет права и сообщает в чат, если их не хватает
+func (b *Bot) allowed(actor Actor, need domain.Role) bool {
+	ok, why := b.authorize(actor, need)
+	if !ok {
+		b.MessageWithReplyMarkup(actor.ChatID, why, actionButtons)
+	}
+	return ok
+}
+
+// EnsureAdmins выдает роль admin пользователям из конфигурации.
+// Без администраторов никому нельзя выдать роль, поэтому об этом предупреждаем при старте
+func (b *Bot) EnsureAdmins(ids []int64) {
+	if len(ids) == 0 {
+		b.warnNoAdmins()
+	}
+	for _, id := range ids {
+		user, err := b.users.GetUser(id)
+		if err != nil {
+			slog.Error("Не удалось получить пользователя", "userID", id, "error", err)
+			continue
+		}
+		if user != nil && user.Role == domain.RoleAdmin {
+			continue
+		}
+
+		admin := domain.User{ID: id, Role: domain.RoleAdmin}
+		if user != nil {
+			admin.Name = user.Name
+		}
+		if err = b.users.SaveUser(admin); err != nil {
+			slog.Error("Не удалось назначить администратора", "userID", id, "error", err)
+		}
+	}
+}
+
+// warnNoAdmins пишет в лог предупреждение, если в базе нет ни одного администратора
+func (b *Bot) warnNoAdmins() {
+	users, err := b.users.ListUsers()
+	if err != nil {
+		slog.Error("Не удалось получить список пользователей", "error", err)
+		return
+	}
+	for _, u := range users {
+		if u.Role == domain.RoleAdmin {
+			return
+		}
+	}
+	slog.Warn("TG_ADMINS не задан и администраторов нет: команды бота недоступны, пока не назначен администратор")
+}
+
+func (b *Bot) listUsers(actor Actor) {
+	users, err := b.users.ListUsers()
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось получить список пользователей", actionButtons)
+		return
+	}
+	if len(users) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Список доступа пуст", actionButtons)
+		return
+	}
+
+	out := make([]string, len(users))
+	for i, u := range users {
+		out[i] = fmt.Sprintf("%d) %d %s — %s", i+1, u.ID, u.Name, u.Role)
+	}
+	b.MessageWithReplyMarkup(actor.ChatID, "Пользователи:\n"+strings.Join(out, "\n"), actionButtons)
+}
+
+func (b *Bot) grantRole(actor Actor, args []string) {
+	usage := "Использование: /grant <telegram id> <viewer|operator|admin>"
+	if len(args) != 2 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	role, ok := domain.ParseRole(args[1])
+	if err != nil || !ok {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	user := domain.User{ID: id, Role: role}
+	if prev, err := b.users.GetUser(id); err == nil && prev != nil {
+		user.Name = prev.Name
+	}
+	if err = b.users.SaveUser(user); err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось сохранить пользователя", actionButtons)
+		return
+	}
+
+	slog.Info("Роль выдана", "by", actor.UserID, "userID", id, "role", role.String())
+	b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Пользователю %d выдана роль %s", id, role), actionButtons)
+}
+
+func (b *Bot) revokeRole(actor Actor, args []string) {
+	if len(args) != 1 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Использование: /revoke <telegram id>", actionButtons)
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Использование: /revoke <telegram id>", actionButtons)
+		return
+	}
+	if id == actor.UserID {
+		b.MessageWithReplyMarkup(actor.ChatID, "Нельзя отозвать доступ у самого себя", actionButtons)
+		return
+	}
+
+	if err = b.users.DeleteUser(id); err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось удалить пользователя", actionButtons)
+		return
+	}
+
+	slog.Info("Доступ отозван", "by", actor.UserID, "userID", id)
+	b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Доступ пользователя %d отозван", id), actionButtons)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envConfig читает настройки из переменных окружения и запоминает первую ошибку разбора,
+// чтобы не проверять каждое значение отдельно
+type envConfig struct {
+	err error
+}
+
+func (e *envConfig) string(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func (e *envConfig) int(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.fail(key, v, err)
+		return def
+	}
+	return n
+}
+
+func (e *envConfig) bool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.fail(key, v, err)
+		return def
+	}
+	return b
+}
+
+func (e *envConfig) duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		e.fail(key, v, err)
+		return def
+	}
+	return d
+}
+
+func (e *envConfig) fail(key, value string, err error) {
+	if e.err == nil {
+		e.err = fmt.Errorf("invalid %s=%q: %w", key, value, err)
+	}
+}
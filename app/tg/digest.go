@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	digestTopConsumers   = 5
+	digestMaxFiring      = 10
+	digestMaxUnavailable = 20
+)
+
+// RunDigests раз в interval отправляет сводки чатам, у которых наступило время по расписанию
+func (b *Bot) RunDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.sendDueDigests(now)
+		}
+	}
+}
+
+func (b *Bot) sendDueDigests(now time.Time) {
+	schedules, err := b.digests.GetDigestSchedules()
+	if err != nil {
+		slog.Error("Не удалось получить расписания сводок", "error", err)
+		return
+	}
+
+	for _, s := range schedules {
+		if !s.Due(now) {
+			continue
+		}
+		// Отмечаем отправку только после успеха, иначе сводка будет отправлена на следующем тике
+		if err = b.sendDigest(s.ChatID, s.Interval(), now); err != nil {
+			slog.Error("Не удалось отправить сводку", "chatID", s.ChatID, "error", err)
+			continue
+		}
+		if err = b.digests.MarkDigestSent(s.ChatID, now); err != nil {
+			slog.Error("Не удалось отметить отправку сводки", "chatID", s.ChatID, "error", err)
+		}
+	}
+}
+
+func (b *Bot) sendDigest(chatID int64, period time.Duration, now time.Time) error {
+	namespaces := b.subs.Namespaces(chatID)
+	if len(namespaces) == 0 {
+		return nil
+	}
+	slog.Info("Отправка сводки", "chatID", chatID, "period", period)
+	msg := tgbotapi.NewMessage(chatID, truncateText(b.buildDigest(namespaces, period, now), maxMessageLen))
+	msg.ReplyMarkup = actionButtons
+	_, err := b.sender.Send(msg)
+	return err
+}
+
+// buildDigest собирает сводку: инциденты за период, активные алерты,
+// недоступные deployments и поды, потребляющие больше всего ресурсов
+func (b *Bot) buildDigest(namespaces []string, period time.Duration, now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📋 Сводка за %s (%s)\n", period, strings.Join(namespaces, ", "))
+
+	alerts, err := b.repo.FindAlerts(domain.AlertFilter{
+		Namespaces: namespaces,
+		From:       now.Add(-period),
+		To:         now,
+		Limit:      domain.NoAlertLimit,
+	})
+	if err != nil {
+		slog.Error("Не удалось получить инциденты для сводки", "error", err)
+		sb.WriteString("\nИнциденты: не удалось получить\n")
+	} else if stats := domain.CalculateStats(alerts, domain.StatsByNamespace, statsTopAlerts); len(stats) == 0 {
+		sb.WriteString("\nИнцидентов не было\n")
+	} else {
+		sb.WriteString("\nИнциденты:\n")
+		for _, s := range stats {
+			sb.WriteString(s.String() + "\n")
+		}
+	}
+
+	firing, err := b.repo.FindAlerts(domain.AlertFilter{
+		Namespaces: namespaces,
+		Status:     domain.StatusFiring,
+		Limit:      digestMaxFiring,
+	})
+	if err != nil {
+		slog.Error("Не удалось получить активные алерты для сводки", "error", err)
+	} else if len(firing) > 0 {
+		sb.WriteString("\nАктивные алерты:\n")
+		for _, a := range firing {
+			sb.WriteString(incidentLine(a) + "\n")
+		}
+	}
+
+	deploys, err := b.k8sController.StatusAll(context.Background())
+	if err != nil {
+		slog.Error("Не удалось получить статус deployments для сводки", "error", err)
+		sb.WriteString("\nСтатус deployments: не удалось получить\n")
+		return sb.String()
+	}
+	deploys = slices.DeleteFunc(deploys, func(d domain.DeployStatus) bool {
+		return !slices.Contains(namespaces, d.Namespace)
+	})
+
+	var unavailable []string
+	for _, d := range deploys {
+		if d.Status != domain.DeploymentAvailable {
+			unavailable = append(unavailable, fmt.Sprintf("%s/%s: %s", d.Namespace, d.Name, d.Status))
+		}
+	}
+	if len(unavailable) > digestMaxUnavailable {
+		rest := len(unavailable) - digestMaxUnavailable
+		unavailable = append(unavailable[:digestMaxUnavailable], fmt.Sprintf("... и еще %d", rest))
+	}
+	if len(unavailable) > 0 {
+		sb.WriteString("\nНедоступные deployments:\n" + strings.Join(unavailable, "\n") + "\n")
+	} else {
+		sb.WriteString("\nВсе deployments доступны ✅\n")
+	}
+
+	cpu, mem := topConsumers(deploys, digestTopConsumers)
+	if len(cpu) > 0 {
+		sb.WriteString("\nТоп по CPU:\n" + strings.Join(cpu, "\n") + "\n")
+		sb.WriteString("\nТоп по памяти:\n" + strings.Join(mem, "\n") + "\n")
+	}
+
+	return sb.String()
+}
+
+// truncateText обрезает текст до max символов, отмечая обрезку многоточием
+func truncateText(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// topConsumers возвращает поды с наибольшим потреблением CPU и памяти
+func topConsumers(deploys []domain.DeployStatus, n int) (cpu, mem []string) {
+	type podUsage struct {
+		name string
+		pod  domain.PodStatus
+	}
+
+	var pods []podUsage
+	for _, d := range deploys {
+		for name, pod := range d.Pods {
+			pods = append(pods, podUsage{name: d.Namespace + "/" + name, pod: pod})
+		}
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].pod.TotalCPU > pods[j].pod.TotalCPU })
+	for _, p := range pods[:min(n, len(pods))] {
+		cpu = append(cpu, fmt.Sprintf("%s: %.3f cores", p.name, p.pod.TotalCPU))
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].pod.TotalMem > pods[j].pod.TotalMem })
+	for _, p := range pods[:min(n, len(pods))] {
+		mem = append(mem, fmt.Sprintf("%s: %.1f MB", p.name, p.pod.TotalMem))
+	}
+
+	return cpu, mem
+}
+
+// manageDigest настраивает сводку чата:
+// /digest, /digest daily <HH:MM>, /digest weekly <mon..sun> <HH:MM>, /digest off, /digest now
+func (b *Bot) manageDigest(actor Actor, args []string) {
+	usage := "Использование: /digest daily <HH:MM> | /digest weekly <mon..sun> <HH:MM> | /digest off | /digest now"
+
+	if len(args) == 0 {
+		s, err := b.digests.GetDigestSchedule(actor.ChatID)
+		if err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось получить расписание сводки", actionButtons)
+			return
+		}
+		if s == nil {
+			b.MessageWithReplyMarkup(actor.ChatID, "Сводка не настроена\n"+usage, actionButtons)
+			return
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, "Сводка: "+s.String(), actionButtons)
+		return
+	}
+
+	schedule := domain.DigestSchedule{ChatID: actor.ChatID, Period: args[0], CreatedAt: time.Now()}
+	var at string
+	switch {
+	case args[0] == "off" && len(args) == 1:
+		if err := b.digests.DeleteDigestSchedule(actor.ChatID); err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось отключить сводку", actionButtons)
+			return
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, "Сводка отключена", actionButtons)
+		return
+	case args[0] == "now" && len(args) <= 2:
+		period := 24 * time.Hour
+		if len(args) == 2 && args[1] == domain.DigestWeekly {
+			period = domain.OnCallWeek
+		}
+		if err := b.sendDigest(actor.ChatID, period, time.Now()); err != nil {
+			slog.Error("Не удалось отправить сводку", "chatID", actor.ChatID, "error", err)
+		}
+		return
+	case args[0] == domain.DigestDaily && len(args) == 2:
+		at = args[1]
+	case args[0] == domain.DigestWeekly && len(args) == 3:
+		weekday, ok := weekdays[strings.ToLower(args[1])]
+		if !ok {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		schedule.Weekday = weekday
+		at = args[2]
+	default:
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	schedule.Hour, schedule.Minute = t.Hour(), t.Minute()
+
+	if err = b.digests.SaveDigestSchedule(schedule); err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось сохранить расписание сводки", actionButtons)
+		return
+	}
+	slog.Info("Расписание сводки обновлено", "chatID", actor.ChatID, "by", actor.UserID, "schedule", schedule.String())
+	b.MessageWithReplyMarkup(actor.ChatID, "Сводка: "+schedule.String(), actionButtons)
+}
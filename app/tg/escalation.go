@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunEscalations периодически проверяет неподтвержденные алерты и эскалирует их по политикам namespace
+func (b *Bot) RunEscalations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.escalate(now)
+		}
+	}
+}
+
+func (b *Bot) escalate(now time.Time) {
+	policies, err := b.escalations.GetEscalationPolicies()
+	if err != nil || len(policies) == 0 {
+		return
+	}
+	byNamespace := make(map[string]domain.EscalationPolicy, len(policies))
+	for _, p := range policies {
+		byNamespace[p.Namespace] = p
+	}
+
+	alerts, err := b.repo.GetUnackedAlerts()
+	if err != nil {
+		slog.Error("Не удалось получить неподтвержденные алерты", "error", err)
+		return
+	}
+
+	silences := b.activeSilences(now)
+	for _, unit := range escalationUnits(alerts) {
+		// Группа эскалируется одним сообщением, пока в ней есть неподтвержденный активный алерт.
+		// Сроки считаются по первому такому алерту
+		a := unit[0]
+		policy, ok := byNamespace[a.Namespace]
+		if !ok || !policy.Due(a, now) {
+			continue
+		}
+		if _, silenced := domain.FindSilence(silences, a, now); silenced {
+			continue
+		}
+
+		level := a.EscalationLevel + 1
+		saved := false
+		for _, member := range unit {
+			if err = b.repo.SetEscalation(member.ID, level, now); err != nil {
+				slog.Error("Не удалось сохранить уровень эскалации", "alertID", member.ID, "error", err)
+				continue
+			}
+			saved = true
+		}
+		if !saved {
+			continue
+		}
+		a.EscalationLevel, a.EscalatedAt = level, now
+
+		slog.Info("Эскалация алерта", "alertID", a.ID, "groupID", a.GroupLeaderID(), "namespace", a.Namespace, "level", level)
+		prefix := fmt.Sprintf("⏰ Эскалация (уровень %d): алерт не взят в работу уже %s\n", level, a.Duration())
+
+		targets := b.subs.ChatIDs(a.Namespace)
+		if level >= 2 {
+			targets = append(targets, policy.ChatIDs...)
+			targets = append(targets, policy.UserIDs...)
+		}
+		slices.Sort(targets)
+		for _, chatID := range slices.Compact(targets) {
+			if err = b.sendEscalation(chatID, a, prefix); err != nil {
+				slog.Error("Не удалось отправить эскалацию", "alertID", a.ID, "chatID", chatID, "error", err)
+			}
+		}
+	}
+}
+
+// escalationUnits разбивает неподтвержденные алерты на эскалируемые единицы: отдельный алерт
+// или все неподтвержденные алерты одной группы в порядке ID
+func escalationUnits(alerts []domain.Alert) [][]domain.Alert {
+	var units [][]domain.Alert
+	groups := map[int64]int{}
+	for _, a := range alerts {
+		leaderID := a.GroupLeaderID()
+		if leaderID == 0 {
+			units = append(units, []domain.Alert{a})
+			continue
+		}
+		if i, ok := groups[leaderID]; ok {
+			units[i] = append(units[i], a)
+			continue
+		}
+		groups[leaderID] = len(units)
+		units = append(units, []domain.Alert{a})
+	}
+	return units
+}
+
+// sendEscalation отправляет напоминание об алерте отдельным сообщением. Оно не сохраняется
+// в сообщениях алерта: подтверждение и разрешение обновляют исходное сообщение
+func (b *Bot) sendEscalation(chatID int64, a domain.Alert, prefix string) error {
+	text, keyboard, ok := b.renderAlertMessage(a, prefix)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if ok {
+		msg.ReplyMarkup = keyboard
+	}
+	_, err := b.sender.Send(msg)
+	return err
+}
+
+// manageEscalation показывает и настраивает политики эскалации:
+// /escalation, /escalation <namespace> <минуты> [chat:<id>] [user:<id>], /escalation <namespace> off
+func (b *Bot) manageEscalation(actor Actor, args []string) {
+	usage := "Использование: /escalation <namespace> <минуты> [chat:<id>] [user:<id>] или /escalation <namespace> off"
+
+	if len(args) == 0 {
+		policies, err := b.escalations.GetEscalationPolicies()
+		if err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось получить политики эскалации", actionButtons)
+			return
+		}
+
+		namespaces := b.subs.Namespaces(actor.ChatID)
+		var out []string
+		for _, p := range policies {
+			if slices.Contains(namespaces, p.Namespace) {
+				out = append(out, p.String())
+			}
+		}
+		if len(out) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Политики эскалации не настроены\n"+usage, actionButtons)
+			return
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, "Политики эскалации:\n"+strings.Join(out, "\n"), actionButtons)
+		return
+	}
+
+	if len(args) < 2 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	ns := args[0]
+	if !slices.Contains(b.subs.Namespaces(actor.ChatID), ns) {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+ns, actionButtons)
+		return
+	}
+
+	if args[1] == "off" {
+		if err := b.escalations.DeleteEscalationPolicy(ns); err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось удалить политику эскалации", actionButtons)
+			return
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, "Эскалация для "+ns+" отключена", actionButtons)
+		return
+	}
+
+	minutes, err := strconv.Atoi(args[1])
+	if err != nil || minutes <= 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	policy := domain.EscalationPolicy{Namespace: ns, Timeout: time.Duration(minutes) * time.Minute}
+
+	for _, target := range args[2:] {
+		kind, value, _ := strings.Cut(target, ":")
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		switch kind {
+		case "chat":
+			policy.ChatIDs = append(policy.ChatIDs, id)
+		case "user":
+			policy.UserIDs = append(policy.UserIDs, id)
+		default:
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+	}
+
+	if err = b.escalations.SaveEscalationPolicy(policy); err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось сохранить политику эскалации", actionButtons)
+		return
+	}
+	b.MessageWithReplyMarkup(actor.ChatID, "Политика эскалации сохранена: "+policy.String(), actionButtons)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"hack-a-tone/internal/adapters/export"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExportPeriod = 24 * time.Hour
+	maxExportAlerts     = 10000
+)
+
+// exportIncidents выгружает инциденты чата файлом:
+// /export <csv|json> [since=24h | from=YYYY-MM-DD [to=YYYY-MM-DD]] [namespace=...] [deployment=...] [status=...]
+func (b *Bot) exportIncidents(actor Actor, args []string) {
+	usage := "Использование: /export <csv|json> [since=24h | from=YYYY-MM-DD [to=YYYY-MM-DD]] " +
+		"[namespace=<ns>] [deployment=<имя>] [alertname=<имя>] [status=firing|resolved]"
+
+	if len(args) == 0 || (args[0] != export.FormatCSV && args[0] != export.FormatJSON) {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	format := args[0]
+
+	values := url.Values{}
+	for _, arg := range args[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		values.Add(key, value)
+	}
+
+	now := time.Now()
+	filter, err := domain.ParseAlertFilter(values, now)
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, err.Error()+"\n"+usage, actionButtons)
+		return
+	}
+	if filter.From.IsZero() {
+		filter.From = now.Add(-defaultExportPeriod)
+	}
+	filter.Limit, filter.Offset = maxExportAlerts, 0
+
+	subscribed := b.subs.Namespaces(actor.ChatID)
+	for _, ns := range filter.Namespaces {
+		if !slices.Contains(subscribed, ns) {
+			b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+ns, actionButtons)
+			return
+		}
+	}
+	if len(filter.Namespaces) == 0 {
+		if len(subscribed) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан ни на один namespace, используйте /start", actionButtons)
+			return
+		}
+		filter.Namespaces = subscribed
+	}
+
+	alerts, err := b.repo.FindAlerts(filter)
+	if err != nil {
+		slog.Error("Не удалось получить инциденты для выгрузки", "chatID", actor.ChatID, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось выгрузить инциденты", actionButtons)
+		return
+	}
+	if len(alerts) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Инцидентов за выбранный период нет", actionButtons)
+		return
+	}
+
+	var buf bytes.Buffer
+	if format == export.FormatCSV {
+		err = export.WriteCSV(&buf, alerts)
+	} else {
+		err = export.WriteJSON(&buf, alerts)
+	}
+	if err != nil {
+		slog.Error("Не удалось сформировать выгрузку", "format", format, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось выгрузить инциденты", actionButtons)
+		return
+	}
+
+	to := filter.To
+	if to.IsZero() {
+		to = now
+	}
+	name := fmt.Sprintf("incidents_%s_%s.%s", filter.From.Format("20060102-1504"), to.Format("20060102-1504"), format)
+
+	doc := tgbotapi.NewDocumentUpload(actor.ChatID, tgbotapi.FileBytes{Name: name, Bytes: buf.Bytes()})
+	doc.Caption = fmt.Sprintf("Инцидентов: %d", len(alerts))
+	if len(alerts) == maxExportAlerts {
+		doc.Caption += fmt.Sprintf(" (выгружены последние %d, сузьте период)", maxExportAlerts)
+	}
+	if _, err = b.sender.Send(doc); err != nil {
+		slog.Error("Не удалось отправить выгрузку", "chatID", actor.ChatID, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось отправить файл с инцидентами", actionButtons)
+		return
+	}
+	slog.Info("Инциденты выгружены", "chatID", actor.ChatID, "by", actor.UserID, "format", format, "count", len(alerts))
+}
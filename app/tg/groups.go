@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"hack-a-tone/internal/core/domain"
+	"html"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// notifyGroup обновляет сообщение группы в чатах, где оно уже есть, и отправляет его в остальные
+// подписанные чаты. Сообщение хранится за первым алертом группы, какой бы алерт его ни отправил:
+// так группа доходит до чата, даже если сообщение первого алерта было объединено с другими
+// или не отправилось
+func (b *Bot) notifyGroup(leaderID int64) error {
+	unlock := b.groupLocks.lock(leaderID)
+	defer unlock()
+
+	leader, err := b.repo.GetAlert(leaderID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert group %d: %w", leaderID, err)
+	}
+	if leader == nil {
+		return nil
+	}
+	msgs, err := b.repo.GetAlertMessages(leaderID)
+	if err != nil {
+		return fmt.Errorf("failed to get alert group %d messages: %w", leaderID, err)
+	}
+
+	var errs []error
+	if len(msgs) > 0 {
+		errs = append(errs, b.refreshAlertMessages(*leader))
+	}
+	errs = append(errs, b.deliverAlert(*leader, msgs))
+	return errors.Join(errs...)
+}
+
+// keyedLocks блокировки по ID группы. Мьютекс удаляется, когда его никто не ждет
+type keyedLocks struct {
+	mu    sync.Mutex
+	locks map[int64]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (k *keyedLocks) lock(key int64) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[int64]*keyedLock{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// renderAlertMessage собирает текст и кнопки сообщения об алерте. Для группы из нескольких
+// алертов сообщение описывает всю группу
+func (b *Bot) renderAlertMessage(a domain.Alert, prefix string) (string, tgbotapi.InlineKeyboardMarkup, bool) {
+	if leaderID := a.GroupLeaderID(); leaderID != 0 {
+		group, err := b.repo.GetGroupAlerts(leaderID)
+		if err != nil {
+			slog.Error("Не удалось получить алерты группы", "leaderID", leaderID, "error", err)
+		} else if len(group) > 1 {
+			return b.renderGroup(group, prefix)
+		}
+	}
+
+	keyboard, ok := alertKeyboard(a)
+	return b.renderAlert(a, prefix), keyboard, ok
+}
+
+func (b *Bot) renderGroup(group []domain.Alert, prefix string) (string, tgbotapi.InlineKeyboardMarkup, bool) {
+	summary := domain.SummarizeGroup(group)
+	text := html.EscapeString(prefix + summary.String())
+	if summary.IsResolved() {
+		return text, tgbotapi.InlineKeyboardMarkup{}, false
+	}
+
+	if userID, _, ok := b.onCall(summary.Namespace, time.Now()); ok {
+		text += "\n\tOn-call: " + b.mention(userID)
+	}
+
+	acked := true
+	for _, a := range group {
+		if !a.IsResolved() && !a.IsAcked() {
+			acked = false
+		}
+	}
+	leader := group[0]
+	if leader.Namespace == "" {
+		return text, tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	// Кнопка перезапуска пода в группе не показывается: подов несколько
+	return text, actionKeyboard(leader.ID, acked, "", leader.Deployment), true
+}
+
+// ackGroup берет в работу все активные алерты группы
+func (b *Bot) ackGroup(cq *tgbotapi.CallbackQuery, a domain.Alert) {
+	group, err := b.repo.GetGroupAlerts(a.GroupLeaderID())
+	if err != nil {
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, "Не удалось получить алерты группы"))
+		return
+	}
+
+	actor := callbackActor(cq)
+	now := time.Now()
+	acked := 0
+	for _, member := range group {
+		if member.IsResolved() || member.IsAcked() {
+			continue
+		}
+		if err = b.repo.AckAlert(member.ID, actor.UserID, actor.UserName, now); err != nil {
+			slog.Error("Не удалось подтвердить алерт группы", "alertID", member.ID, "error", err)
+			continue
+		}
+		acked++
+	}
+	if acked == 0 {
+		b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, "Все алерты группы уже в работе"))
+		return
+	}
+	slog.Info("Группа алертов подтверждена", "leaderID", a.GroupLeaderID(), "count", acked, "user", actor.UserID)
+
+	b.refreshAlertMessages(a)
+	b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, fmt.Sprintf("Взято в работу алертов: %d", acked)))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const incidentsPageSize = 10
+
+// searchIncidents ищет инциденты по фильтру:
+// /incidents [deployment=api] [alertname=...] [pod=...] [status=firing|resolved] [since=24h] [from=2024-05-01] [to=...] [page=2]
+func (b *Bot) searchIncidents(actor Actor, args []string) {
+	usage := "Использование: /incidents [namespace=<ns>] [deployment=<имя>] [alertname=<имя>] [pod=<под>] " +
+		"[status=firing|resolved] [since=24h] [from=YYYY-MM-DD] [to=YYYY-MM-DD] [page=<номер>]"
+
+	values := url.Values{}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		values.Add(key, value)
+	}
+
+	page := 1
+	if v := values.Get("page"); v != "" {
+		var err error
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		values.Del("page")
+	}
+
+	filter, err := domain.ParseAlertFilter(values, time.Now())
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, err.Error()+"\n"+usage, actionButtons)
+		return
+	}
+	filter.Limit = incidentsPageSize
+	filter.Offset = (page - 1) * incidentsPageSize
+
+	subscribed := b.subs.Namespaces(actor.ChatID)
+	for _, ns := range filter.Namespaces {
+		if !slices.Contains(subscribed, ns) {
+			b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+ns, actionButtons)
+			return
+		}
+	}
+	if len(filter.Namespaces) == 0 {
+		if len(subscribed) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан ни на один namespace, используйте /start", actionButtons)
+			return
+		}
+		filter.Namespaces = subscribed
+	}
+
+	alerts, err := b.repo.FindAlerts(filter)
+	if err != nil {
+		slog.Error("Не удалось найти инциденты", "chatID", actor.ChatID, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось найти инциденты", actionButtons)
+		return
+	}
+	if len(alerts) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Инцидентов не найдено", actionButtons)
+		return
+	}
+
+	lines := make([]string, 0, len(alerts)+1)
+	for _, a := range alerts {
+		lines = append(lines, incidentLine(a))
+	}
+	if len(alerts) == incidentsPageSize {
+		lines = append(lines, fmt.Sprintf("\nСледующая страница: page=%d", page+1))
+	}
+	b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Инциденты, страница %d:\n", page)+strings.Join(lines, "\n"), actionButtons)
+}
+
+// incidentLine краткое описание инцидента в одну строку
+func incidentLine(a domain.Alert) string {
+	started := a.StartsAt
+	if started.IsZero() {
+		started = a.CreatedAt
+	}
+
+	target := a.Namespace
+	if a.Deployment != "" {
+		target += "/" + a.Deployment
+	}
+	status := "🚨"
+	if a.IsResolved() {
+		status = "✅"
+	}
+
+	line := fmt.Sprintf("#%d %s %s %s [%s]", a.ID, started.Local().Format("02.01 15:04"), status, a.Labels.Alertname, target)
+	if a.Labels.Pod != "" {
+		line += " " + a.Labels.Pod
+	}
+	if d := a.Duration(); d > 0 {
+		line += " " + d.String()
+	}
+	return line
+}
@@ -2,30 +2,49 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"hack-a-tone/internal/adapters"
+	"hack-a-tone/internal/adapters/httpapi"
+	"hack-a-tone/internal/adapters/queue"
+	"hack-a-tone/internal/adapters/retention"
 	"hack-a-tone/internal/adapters/storage"
+	"hack-a-tone/internal/adapters/webhook"
 	"hack-a-tone/internal/core/domain"
-	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
-const waitTime = 2 * time.Second
+const (
+	waitTime           = 2 * time.Second
+	escalationInterval = 30 * time.Second
+	digestInterval     = time.Minute
+)
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	db, err := storage.NewSQLRepo()
+	slog.SetDefault(adapters.SetupLogger(adapters.EnvLocal))
+
+	dbConfig, err := storageConfig()
+	if err != nil {
+		slog.Error("Некорректные настройки базы данных", "error", err)
+		return
+	}
+	db, err := storage.NewSQLRepo(dbConfig)
 	if err != nil {
 		slog.Error("Не удалось создать репозиторий", "error", err)
+		return
 	}
-
-	slog.SetDefault(adapters.SetupLogger(adapters.EnvLocal))
+	defer db.Close()
 
 	controller := adapters.NewKubeRuntimeController()
 	err = controller.Start(ctx)
@@ -35,34 +54,266 @@ func main() {
 		return
 	}
 
-	b := NewBot(os.Getenv("TG_BOT_KEY"), controller, db)
+	senderConfig, err := telegramSenderConfig()
+	if err != nil {
+		slog.Error("Некорректные настройки отправки в Telegram", "error", err)
+		return
+	}
+	grouping, err := alertGrouping()
+	if err != nil {
+		slog.Error("Некорректные настройки группировки алертов", "error", err)
+		return
+	}
+
+	b := NewBot(os.Getenv("TG_BOT_KEY"), controller, Stores{
+		Alerts:        db,
+		Subscriptions: db,
+		Users:         db,
+		Audit:         db,
+		Escalations:   db,
+		OnCall:        db,
+		Silences:      db,
+		Digests:       db,
+	}, Options{Sender: senderConfig, Grouping: grouping})
+	if b == nil {
+		return
+	}
+	b.EnsureAdmins(parseIDs(os.Getenv("TG_ADMINS")))
+	go b.RunEscalations(ctx, escalationInterval)
+	go b.RunDigests(ctx, digestInterval)
+
+	pruner, retentionInterval, err := newPruner(db)
+	if err != nil {
+		slog.Error("Некорректные настройки хранения алертов", "error", err)
+		return
+	}
+	go pruner.Run(ctx, retentionInterval)
+
+	queueConfig, err := alertQueueConfig()
+	if err != nil {
+		slog.Error("Некорректные настройки очереди алертов", "error", err)
+		return
+	}
+	alertQueue := queue.NewQueue(db, b, queueConfig)
+	queueDone := make(chan struct{})
+	go func() {
+		defer close(queueDone)
+		alertQueue.Run(ctx)
+	}()
+
+	authConfig, err := webhookAuthConfig()
+	if err != nil {
+		slog.Error("Некорректные настройки аутентификации вебхука", "error", err)
+		return
+	}
+	if !authConfig.Enabled() {
+		slog.Warn("Аутентификация вебхука не настроена, /alert принимает запросы от всех")
+	}
+	auth := webhook.NewAuth(authConfig)
+
+	apiConfig := apiAuthConfig(authConfig)
+	if !apiConfig.Enabled() {
+		slog.Warn("Аутентификация API не настроена, /api и /debug/vars доступны всем")
+	}
+	apiAuth := webhook.NewAuth(apiConfig)
+
+	serverConfig, err := httpServerConfig()
+	if err != nil {
+		slog.Error("Некорректные настройки HTTP-сервера", "error", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/alert", auth.Wrap(webhook.NewHandler(alertQueue)))
+	mux.Handle("/api/alerts", apiAuth.Wrap(httpapi.NewAlertsHandler(db)))
+	mux.Handle("/api/stats", apiAuth.Wrap(httpapi.NewStatsHandler(db)))
+	mux.Handle("/debug/vars", apiAuth.Wrap(expvar.Handler()))
+	server := httpapi.NewServer(serverConfig, mux)
 
+	serverDone := make(chan struct{})
 	go func() {
-		http.HandleFunc("/alert", func(w http.ResponseWriter, r *http.Request) {
-			slog.Info("Got alert! Trying ro read body")
-			body, err := io.ReadAll(r.Body)
-			if err != nil {
-				slog.Error("reading alert request body:", err)
-				http.Error(w, "Error reading request body", http.StatusBadRequest)
-				return
-			}
-			defer r.Body.Close()
-			slog.Info("Read alert body", "body", string(body))
-
-			var alerts domain.Alerts
-			err = json.Unmarshal(body, &alerts)
-			if err != nil {
-				slog.Error("unmarshalling alert:", err, "alert body:", string(body))
-				return
-			}
-
-			for _, alert := range alerts {
-				b.SendAlert(alert)
-			}
-		})
-
-		http.ListenAndServe(":3030", nil)
+		defer close(serverDone)
+		if err := server.Run(ctx); err != nil {
+			slog.Error("Ошибка HTTP-сервера", "error", err)
+			stop()
+		}
 	}()
 
-	b.start()
+	b.start(ctx)
+	// start возвращается и при ошибке получения обновлений, тогда останавливаем остальное сами
+	stop()
+	<-serverDone
+	// Дожидаемся пачки алертов, которая обрабатывается в момент остановки
+	<-queueDone
+}
+
+// parseIDs разбирает список Telegram ID, разделенных запятыми
+func parseIDs(s string) []int64 {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			slog.Error("Некорректный Telegram ID в конфигурации", "value", part, "error", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// storageConfig читает настройки базы: DB_DRIVER (sqlite|postgres), DB_DSN, DB_WAL, DB_BUSY_TIMEOUT,
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME
+func storageConfig() (storage.Config, error) {
+	var env envConfig
+	cfg := storage.Config{
+		Driver:          env.string("DB_DRIVER", storage.DriverSQLite),
+		DSN:             env.string("DB_DSN", ""),
+		WAL:             env.bool("DB_WAL", true),
+		BusyTimeout:     env.duration("DB_BUSY_TIMEOUT", 5*time.Second),
+		MaxOpenConns:    env.int("DB_MAX_OPEN_CONNS", 0),
+		MaxIdleConns:    env.int("DB_MAX_IDLE_CONNS", 0),
+		ConnMaxLifetime: env.duration("DB_CONN_MAX_LIFETIME", 0),
+	}
+	return cfg, env.err
+}
+
+// newPruner настраивает очистку алертов: RETENTION_MAX_AGE, RETENTION_MAX_PER_NAMESPACE,
+// RETENTION_INTERVAL и RETENTION_ARCHIVE_DIR, если удаляемые алерты нужно сохранять в архив
+func newPruner(db *storage.SQLRepo) (*retention.Pruner, time.Duration, error) {
+	var env envConfig
+	policy := domain.RetentionPolicy{
+		MaxAge:          env.duration("RETENTION_MAX_AGE", 0),
+		MaxPerNamespace: env.int("RETENTION_MAX_PER_NAMESPACE", 0),
+	}
+	interval := env.duration("RETENTION_INTERVAL", time.Hour)
+	archiveDir := env.string("RETENTION_ARCHIVE_DIR", "")
+	if env.err != nil {
+		return nil, 0, env.err
+	}
+	if interval <= 0 {
+		return nil, 0, fmt.Errorf("RETENTION_INTERVAL must be positive, got %s", interval)
+	}
+
+	var archiver retention.Archiver
+	if archiveDir != "" {
+		gz, err := retention.NewGzipArchiver(archiveDir)
+		if err != nil {
+			return nil, 0, err
+		}
+		archiver = gz
+	}
+
+	return retention.NewPruner(db, policy, archiver), interval, nil
+}
+
+// webhookAuthConfig читает настройки проверки вебхука: WEBHOOK_BEARER_TOKEN, WEBHOOK_BASIC_USER,
+// WEBHOOK_BASIC_PASSWORD, WEBHOOK_HMAC_SECRET, WEBHOOK_HMAC_HEADER, WEBHOOK_HMAC_MAX_SKEW
+// и WEBHOOK_HMAC_TIMESTAMP_HEADER
+func webhookAuthConfig() (webhook.AuthConfig, error) {
+	var env envConfig
+	cfg := webhook.AuthConfig{
+		Name:          "webhook",
+		BearerToken:   env.string("WEBHOOK_BEARER_TOKEN", ""),
+		BasicUser:     env.string("WEBHOOK_BASIC_USER", ""),
+		BasicPassword: env.string("WEBHOOK_BASIC_PASSWORD", ""),
+		HMACSecret:    env.string("WEBHOOK_HMAC_SECRET", ""),
+		HMACHeader:    env.string("WEBHOOK_HMAC_HEADER", webhook.DefaultSignatureHeader),
+
+		MaxSkew:         env.duration("WEBHOOK_HMAC_MAX_SKEW", 0),
+		TimestampHeader: env.string("WEBHOOK_HMAC_TIMESTAMP_HEADER", webhook.DefaultTimestampHeader),
+	}
+	if env.err != nil {
+		return cfg, env.err
+	}
+	if cfg.MaxSkew < 0 {
+		return cfg, fmt.Errorf("WEBHOOK_HMAC_MAX_SKEW must not be negative, got %s", cfg.MaxSkew)
+	}
+	return cfg, nil
+}
+
+// apiAuthConfig читает токен API из API_TOKEN. Без него API принимает те же учетные данные,
+// что и вебхук. Подпись тела для GET-запросов не проверяется
+func apiAuthConfig(webhookCfg webhook.AuthConfig) webhook.AuthConfig {
+	var env envConfig
+	if token := env.string("API_TOKEN", ""); token != "" {
+		return webhook.AuthConfig{Name: "api", BearerToken: token}
+	}
+	webhookCfg.Name = "api"
+	webhookCfg.HMACSecret = ""
+	return webhookCfg
+}
+
+// httpServerConfig читает настройки HTTP-сервера: HTTP_ADDR, HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT,
+// HTTP_IDLE_TIMEOUT, HTTP_MAX_BODY_BYTES и HTTP_SHUTDOWN_TIMEOUT
+func httpServerConfig() (httpapi.ServerConfig, error) {
+	var env envConfig
+	cfg := httpapi.ServerConfig{
+		Addr:            env.string("HTTP_ADDR", ":3030"),
+		ReadTimeout:     env.duration("HTTP_READ_TIMEOUT", 10*time.Second),
+		WriteTimeout:    env.duration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:     env.duration("HTTP_IDLE_TIMEOUT", time.Minute),
+		MaxBodyBytes:    int64(env.int("HTTP_MAX_BODY_BYTES", 4<<20)),
+		ShutdownTimeout: env.duration("HTTP_SHUTDOWN_TIMEOUT", 10*time.Second),
+	}
+	return cfg, env.err
+}
+
+// alertQueueConfig читает настройки очереди алертов: QUEUE_WORKERS, QUEUE_BATCH_SIZE, QUEUE_POLL_INTERVAL,
+// QUEUE_LEASE, QUEUE_MAX_ATTEMPTS, QUEUE_RETRY_BASE_DELAY, QUEUE_RETRY_MAX_DELAY и QUEUE_FAILED_RETENTION.
+// Пачка по умолчанию небольшая: при интервале 3с на группу она успевает уйти в Telegram за lease
+func alertQueueConfig() (queue.Config, error) {
+	var env envConfig
+	cfg := queue.Config{
+		Workers:         env.int("QUEUE_WORKERS", 4),
+		BatchSize:       env.int("QUEUE_BATCH_SIZE", 20),
+		PollInterval:    env.duration("QUEUE_POLL_INTERVAL", 5*time.Second),
+		Lease:           env.duration("QUEUE_LEASE", 5*time.Minute),
+		MaxAttempts:     env.int("QUEUE_MAX_ATTEMPTS", 10),
+		BaseDelay:       env.duration("QUEUE_RETRY_BASE_DELAY", 5*time.Second),
+		MaxDelay:        env.duration("QUEUE_RETRY_MAX_DELAY", 10*time.Minute),
+		FailedRetention: env.duration("QUEUE_FAILED_RETENTION", 7*24*time.Hour),
+	}
+	if env.err != nil {
+		return cfg, env.err
+	}
+	if cfg.PollInterval <= 0 || cfg.Lease <= 0 {
+		return cfg, errors.New("QUEUE_POLL_INTERVAL and QUEUE_LEASE must be positive")
+	}
+	if cfg.Workers <= 0 || cfg.BatchSize <= 0 || cfg.MaxAttempts <= 0 {
+		return cfg, errors.New("QUEUE_WORKERS, QUEUE_BATCH_SIZE and QUEUE_MAX_ATTEMPTS must be positive")
+	}
+	return cfg, nil
+}
+
+// telegramSenderConfig читает лимиты отправки в Telegram: TG_RATE_GLOBAL (сообщений в секунду),
+// TG_RATE_CHAT_INTERVAL, TG_RATE_GROUP_INTERVAL и TG_SEND_MAX_RETRIES
+func telegramSenderConfig() (SenderConfig, error) {
+	var env envConfig
+	cfg := SenderConfig{
+		GlobalRate:    env.int("TG_RATE_GLOBAL", defaultGlobalRate),
+		ChatInterval:  env.duration("TG_RATE_CHAT_INTERVAL", defaultChatInterval),
+		GroupInterval: env.duration("TG_RATE_GROUP_INTERVAL", defaultGroupInterval),
+		MaxRetries:    env.int("TG_SEND_MAX_RETRIES", 3),
+	}
+	return cfg, env.err
+}
+
+// alertGrouping читает настройки группировки алертов: ALERT_GROUP_BY (метки через запятую)
+// и ALERT_GROUP_WINDOW. Пустой список меток или нулевое окно отключают группировку
+func alertGrouping() (domain.AlertGrouping, error) {
+	var env envConfig
+	var groupBy []string
+	for _, label := range strings.Split(env.string("ALERT_GROUP_BY", "alertname,deployment"), ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			groupBy = append(groupBy, label)
+		}
+	}
+	return domain.AlertGrouping{
+		GroupBy: groupBy,
+		Window:  env.duration("ALERT_GROUP_WINDOW", 5*time.Minute),
+	}, env.err
 }
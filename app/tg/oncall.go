@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"html"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// onCall возвращает текущего дежурного namespace и время окончания его смены
+func (b *Bot) onCall(namespace string, now time.Time) (int64, time.Time, bool) {
+	schedule, err := b.onCallRepo.GetOnCallSchedule(namespace)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	overrides, err := b.onCallRepo.GetOnCallOverrides(namespace, now)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+
+	userID, ok := domain.CurrentOnCall(schedule, overrides, now)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+
+	var until time.Time
+	if len(overrides) > 0 {
+		until = overrides[len(overrides)-1].To.Local()
+		if schedule != nil {
+			until = until.In(schedule.Zone())
+		}
+	} else {
+		until = schedule.NextHandover(now)
+	}
+	return userID, until, true
+}
+
+// mention формирует HTML-упоминание пользователя, чтобы Telegram прислал ему уведомление
+func (b *Bot) mention(userID int64) string {
+	name := strconv.FormatInt(userID, 10)
+	if user, err := b.users.GetUser(userID); err == nil && user != nil && user.Name != "" {
+		name = user.Name
+	}
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, userID, html.EscapeString(name))
+}
+
+// renderAlert формирует HTML-текст сообщения об алерте с упоминанием дежурного
+func (b *Bot) renderAlert(a domain.Alert, prefix string) string {
+	text := html.EscapeString(prefix + a.String())
+	if a.IsResolved() {
+		return text
+	}
+
+	if userID, _, ok := b.onCall(a.Namespace, time.Now()); ok {
+		text += "\n\tOn-call: " + b.mention(userID)
+	}
+	return text
+}
+
+// showOnCall показывает дежурных: /oncall [namespace]
+func (b *Bot) showOnCall(actor Actor, args []string) {
+	namespaces := b.subs.Namespaces(actor.ChatID)
+	if len(args) > 0 {
+		namespaces = args[:1]
+	}
+	if len(namespaces) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан ни на один namespace, используйте /start", actionButtons)
+		return
+	}
+
+	now := time.Now()
+	out := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		userID, until, ok := b.onCall(ns, now)
+		if !ok {
+			out[i] = fmt.Sprintf("%s: дежурный не назначен", html.EscapeString(ns))
+			continue
+		}
+		out[i] = fmt.Sprintf("%s: %s до %s", html.EscapeString(ns), b.mention(userID), until.Format("02.01 15:04 MST"))
+	}
+
+	b.HTMLMessage(actor.ChatID, "Дежурные:\n"+strings.Join(out, "\n"))
+}
+
+// setOnCall задает еженедельную ротацию: /oncall_set <namespace> <mon..sun> <HH:MM> <id> [id...] [tz:<зона>].
+// Время передачи смены считается в зоне tz, по умолчанию в локальной зоне сервера
+func (b *Bot) setOnCall(actor Actor, args []string) {
+	usage := "Использование: /oncall_set <namespace> <mon|tue|wed|thu|fri|sat|sun> <HH:MM> <telegram id> [telegram id...] [tz:Europe/Moscow]"
+	if len(args) < 4 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	ns := args[0]
+	if !slices.Contains(b.subs.Namespaces(actor.ChatID), ns) {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+ns, actionButtons)
+		return
+	}
+	weekday, ok := weekdays[strings.ToLower(args[1])]
+	handover, err := time.Parse("15:04", args[2])
+	if !ok || err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	schedule := domain.OnCallSchedule{Namespace: ns, Period: domain.OnCallWeek}
+	for _, arg := range args[3:] {
+		if zone, ok := strings.CutPrefix(arg, "tz:"); ok {
+			if schedule.Location, err = time.LoadLocation(zone); err != nil {
+				b.MessageWithReplyMarkup(actor.ChatID, "Неизвестная временная зона "+zone, actionButtons)
+				return
+			}
+			continue
+		}
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		schedule.UserIDs = append(schedule.UserIDs, id)
+	}
+	if len(schedule.UserIDs) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	schedule.Start = domain.LastHandover(time.Now().In(schedule.Zone()), weekday, handover.Hour(), handover.Minute())
+
+	if err = b.onCallRepo.SaveOnCallSchedule(schedule); err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось сохранить расписание дежурств", actionButtons)
+		return
+	}
+	slog.Info("Расписание дежурств обновлено", "namespace", ns, "by", actor.UserID, "users", schedule.UserIDs)
+	b.showOnCall(actor, []string{ns})
+}
+
+// overrideOnCall временно назначает дежурного: /oncall_override <namespace> <id> <часы>
+func (b *Bot) overrideOnCall(actor Actor, args []string) {
+	usage := "Использование: /oncall_override <namespace> <telegram id> <часы>"
+	if len(args) != 3 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	ns := args[0]
+	if !slices.Contains(b.subs.Namespaces(actor.ChatID), ns) {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+ns, actionButtons)
+		return
+	}
+	userID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	hours, err := strconv.Atoi(args[2])
+	if err != nil || hours <= 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	now := time.Now()
+	override := domain.OnCallOverride{Namespace: ns, UserID: userID, From: now, To: now.Add(time.Duration(hours) * time.Hour)}
+	if err = b.onCallRepo.AddOnCallOverride(override); err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось сохранить замену дежурного", actionButtons)
+		return
+	}
+	slog.Info("Назначена замена дежурного", "namespace", ns, "by", actor.UserID, "userID", userID, "hours", hours)
+	b.showOnCall(actor, []string{ns})
+}
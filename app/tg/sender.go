@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Лимиты Telegram: около 30 сообщений в секунду на бота, одно сообщение в секунду в личный чат
+// и 20 сообщений в минуту в группу
+const (
+	defaultGlobalRate    = 30
+	defaultChatInterval  = time.Second
+	defaultGroupInterval = 3 * time.Second
+
+	// maxCoalescedLen оставляет запас до лимита Telegram в 4096 символов
+	maxCoalescedLen = 3500
+	// maxMessageLen предел для длинных ответов вроде сводки и журнала
+	maxMessageLen = 4000
+)
+
+var senderStats = expvar.NewMap("telegram_sender")
+
+type SenderConfig struct {
+	// GlobalRate сообщений в секунду на всего бота
+	GlobalRate int
+	// ChatInterval и GroupInterval минимальный интервал между сообщениями в личный чат и в группу
+	ChatInterval  time.Duration
+	GroupInterval time.Duration
+	// MaxRetries сколько раз повторять отправку после ответа 429
+	MaxRetries int
+}
+
+// Sender единая точка отправки сообщений в Telegram. Соблюдает общий лимит и лимиты чатов,
+// при ответе 429 ждет retry_after и повторяет запрос
+type Sender struct {
+	api *tgbotapi.BotAPI
+	cfg SenderConfig
+
+	mu         sync.Mutex
+	globalNext time.Time
+	chats      map[int64]*chatLimit
+}
+
+type chatLimit struct {
+	next time.Time
+	// batch алерты, которые ждут своей очереди и будут отправлены одним сообщением
+	batch *alertBatch
+}
+
+type alertBatch struct {
+	texts []string
+	size  int
+	done  chan struct{}
+	msg   tgbotapi.Message
+	err   error
+}
+
+func NewSender(api *tgbotapi.BotAPI, cfg SenderConfig) *Sender {
+	if cfg.GlobalRate <= 0 {
+		cfg.GlobalRate = defaultGlobalRate
+	}
+	if cfg.ChatInterval <= 0 {
+		cfg.ChatInterval = defaultChatInterval
+	}
+	if cfg.GroupInterval <= 0 {
+		cfg.GroupInterval = defaultGroupInterval
+	}
+	return &Sender{api: api, cfg: cfg, chats: map[int64]*chatLimit{}}
+}
+
+// Send дожидается свободного слота и отправляет сообщение
+func (s *Sender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := chattableChatID(c)
+	s.mu.Lock()
+	at := s.reserve(chatID, time.Now())
+	s.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+	return s.deliver(c, chatID)
+}
+
+// SendAlert отправляет сообщение об алерте. Если чат упирается в лимит и сообщение ждет очереди,
+// к нему присоединяются следующие алерты, и уходит одно сгруппированное сообщение без кнопок.
+// coalesced сообщает, что сообщение содержит несколько алертов
+func (s *Sender) SendAlert(msg tgbotapi.MessageConfig) (sent tgbotapi.Message, coalesced bool, err error) {
+	chatID := msg.ChatID
+	now := time.Now()
+
+	s.mu.Lock()
+	chat := s.chat(chatID)
+	if batch := chat.batch; batch != nil && batch.size+len(msg.Text) <= maxCoalescedLen {
+		batch.texts = append(batch.texts, msg.Text)
+		batch.size += len(msg.Text)
+		s.mu.Unlock()
+
+		<-batch.done
+		return batch.msg, true, batch.err
+	}
+
+	at := s.reserve(chatID, now)
+	if !at.After(now) {
+		s.mu.Unlock()
+		sent, err = s.deliver(msg, chatID)
+		return sent, false, err
+	}
+
+	batch := &alertBatch{texts: []string{msg.Text}, size: len(msg.Text), done: make(chan struct{})}
+	if chat.batch == nil {
+		chat.batch = batch
+	}
+	s.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+
+	s.mu.Lock()
+	if chat.batch == batch {
+		chat.batch = nil
+	}
+	texts := batch.texts
+	s.mu.Unlock()
+
+	if len(texts) > 1 {
+		senderStats.Add("coalesced", int64(len(texts)))
+		slog.Info("Алерты объединены в одно сообщение", "chatID", chatID, "count", len(texts))
+		msg = tgbotapi.NewMessage(chatID, coalescedText(texts))
+		msg.ParseMode = tgbotapi.ModeHTML
+	}
+	batch.msg, batch.err = s.deliver(msg, chatID)
+	close(batch.done)
+
+	return batch.msg, len(texts) > 1, batch.err
+}
+
+// deliver отправляет сообщение, слот для которого уже получен. На ответ 429 чат
+// ставится на паузу retry_after, после чего отправка повторяется
+func (s *Sender) deliver(c tgbotapi.Chattable, chatID int64) (tgbotapi.Message, error) {
+	for attempt := 0; ; attempt++ {
+		msg, err := s.api.Send(c)
+		retryAfter := retryAfterOf(err)
+		if retryAfter == 0 || attempt >= s.cfg.MaxRetries {
+			if err == nil {
+				senderStats.Add("sent", 1)
+			}
+			return msg, err
+		}
+
+		senderStats.Add("throttled", 1)
+		slog.Warn("Telegram ограничил отправку, повтор позже", "chatID", chatID, "retryAfter", retryAfter, "attempt", attempt+1)
+
+		s.mu.Lock()
+		now := time.Now()
+		chat := s.chat(chatID)
+		chat.next = laterOf(chat.next, now.Add(retryAfter))
+		at := s.reserve(chatID, now)
+		s.mu.Unlock()
+
+		time.Sleep(time.Until(at))
+	}
+}
+
+// reserve занимает ближайший слот с учетом лимита чата и общего лимита. Вызывается под s.mu
+func (s *Sender) reserve(chatID int64, now time.Time) time.Time {
+	at := laterOf(now, s.globalNext)
+	var chat *chatLimit
+	if chatID != 0 {
+		chat = s.chat(chatID)
+		at = laterOf(at, chat.next)
+	}
+
+	s.globalNext = at.Add(time.Second / time.Duration(s.cfg.GlobalRate))
+	if chat != nil {
+		chat.next = at.Add(s.chatInterval(chatID))
+	}
+	return at
+}
+
+func (s *Sender) chat(chatID int64) *chatLimit {
+	chat, ok := s.chats[chatID]
+	if !ok {
+		chat = &chatLimit{}
+		s.chats[chatID] = chat
+	}
+	return chat
+}
+
+// chatInterval у групп и каналов отрицательные ID и более строгий лимит
+func (s *Sender) chatInterval(chatID int64) time.Duration {
+	if chatID < 0 {
+		return s.cfg.GroupInterval
+	}
+	return s.cfg.ChatInterval
+}
+
+func coalescedText(texts []string) string {
+	header := "🚨 Несколько алертов за короткое время, сообщения объединены. Подробнее: /incidents"
+	return header + "\n\n" + strings.Join(texts, "\n\n")
+}
+
+func retryAfterOf(err error) time.Duration {
+	var tgErr tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		return time.Duration(tgErr.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// chattableChatID достает чат из запроса, чтобы применить лимит чата. 0 — только общий лимит
+func chattableChatID(c tgbotapi.Chattable) int64 {
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return m.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return m.ChatID
+	case tgbotapi.DocumentConfig:
+		return m.ChatID
+	case tgbotapi.PhotoConfig:
+		return m.ChatID
+	case *tgbotapi.PhotoConfig:
+		return m.ChatID
+	}
+	return 0
+}
+
+func laterOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
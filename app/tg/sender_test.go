@@ -0,0 +1,186 @@
+package main
+
+import (
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTelegram подменяет HTTP-транспорт бота и запоминает тексты отправленных сообщений
+type fakeTelegram struct {
+	mu    sync.Mutex
+	texts []string
+	// replies ответы по порядку, после них отвечает успехом
+	replies []string
+}
+
+func (f *fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	form, _ := url.ParseQuery(string(body))
+
+	f.mu.Lock()
+	f.texts = append(f.texts, form.Get("text"))
+	reply := `{"ok":true,"result":{"message_id":1}}`
+	if len(f.replies) > 0 {
+		reply, f.replies = f.replies[0], f.replies[1:]
+	}
+	f.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(reply)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeTelegram) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+func newTestSender(f *fakeTelegram, cfg SenderConfig) *Sender {
+	api := &tgbotapi.BotAPI{Token: "test", Client: &http.Client{Transport: f}}
+	return NewSender(api, cfg)
+}
+
+func TestSenderReserve(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cfg := SenderConfig{GlobalRate: 10, ChatInterval: time.Second, GroupInterval: 3 * time.Second}
+
+	tests := []struct {
+		name  string
+		chats []int64
+		want  []time.Duration
+	}{
+		{name: "first message is immediate", chats: []int64{1}, want: []time.Duration{0}},
+		{name: "private chat interval", chats: []int64{1, 1, 1}, want: []time.Duration{0, time.Second, 2 * time.Second}},
+		{name: "group interval", chats: []int64{-1, -1}, want: []time.Duration{0, 3 * time.Second}},
+		{
+			name:  "global rate across chats",
+			chats: []int64{1, 2, 3},
+			want:  []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:  "chat without id uses only global rate",
+			chats: []int64{0, 0},
+			want:  []time.Duration{0, 100 * time.Millisecond},
+		},
+		{
+			name:  "chat limit outweighs global rate",
+			chats: []int64{1, 2, 1},
+			want:  []time.Duration{0, 100 * time.Millisecond, time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSender(nil, cfg)
+			for i, chatID := range tt.chats {
+				if got := s.reserve(chatID, now).Sub(now); got != tt.want[i] {
+					t.Errorf("message %d to chat %d at +%s, want +%s", i, chatID, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSenderCoalescesWaitingAlerts(t *testing.T) {
+	f := &fakeTelegram{}
+	s := newTestSender(f, SenderConfig{ChatInterval: 200 * time.Millisecond})
+
+	// Первое сообщение уходит сразу и занимает слот чата
+	if _, coalesced, err := s.SendAlert(tgbotapi.NewMessage(1, "first")); err != nil || coalesced {
+		t.Fatalf("first SendAlert: coalesced=%v, err=%v", coalesced, err)
+	}
+
+	type result struct {
+		coalesced bool
+		err       error
+	}
+	results := make(chan result, 2)
+	send := func(text string) {
+		_, coalesced, err := s.SendAlert(tgbotapi.NewMessage(1, text))
+		results <- result{coalesced, err}
+	}
+
+	go send("second")
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.chat(1).batch != nil
+	})
+	go send("third")
+
+	for range 2 {
+		if r := <-results; r.err != nil || !r.coalesced {
+			t.Errorf("waiting SendAlert: coalesced=%v, err=%v", r.coalesced, r.err)
+		}
+	}
+
+	texts := f.sent()
+	if len(texts) != 2 {
+		t.Fatalf("sent %d messages, want 2: %q", len(texts), texts)
+	}
+	if !strings.Contains(texts[1], "second") || !strings.Contains(texts[1], "third") {
+		t.Errorf("coalesced message = %q", texts[1])
+	}
+}
+
+func TestSenderRetriesAfterTooManyRequests(t *testing.T) {
+	tooMany := `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":1}}`
+
+	tests := []struct {
+		name       string
+		replies    []string
+		maxRetries int
+		wantErr    bool
+		wantCalls  int
+	}{
+		{name: "retried after pause", replies: []string{tooMany}, maxRetries: 1, wantCalls: 2},
+		{name: "no retries left", replies: []string{tooMany}, maxRetries: 0, wantErr: true, wantCalls: 1},
+		{
+			name:       "other errors are not retried",
+			replies:    []string{`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`},
+			maxRetries: 3,
+			wantErr:    true,
+			wantCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeTelegram{replies: tt.replies}
+			s := newTestSender(f, SenderConfig{MaxRetries: tt.maxRetries})
+
+			start := time.Now()
+			_, err := s.Send(tgbotapi.NewMessage(1, "alert"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls := len(f.sent()); calls != tt.wantCalls {
+				t.Errorf("Telegram called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantCalls > 1 && time.Since(start) < time.Second {
+				t.Errorf("retried after %s, want at least retry_after", time.Since(start))
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
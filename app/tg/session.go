@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/Syfaro/telegram-bot-api"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const chatQueueSize = 100
+
+// ChatDispatcher раскладывает обновления по очередям чатов. Каждый чат обрабатывается
+// в своей горутине, поэтому долгая операция в одном чате не блокирует остальные.
+type ChatDispatcher struct {
+	mu      sync.Mutex
+	workers map[int64]chan tgbotapi.Update
+	handle  func(tgbotapi.Update)
+}
+
+func NewChatDispatcher(handle func(tgbotapi.Update)) *ChatDispatcher {
+	return &ChatDispatcher{
+		workers: map[int64]chan tgbotapi.Update{},
+		handle:  handle,
+	}
+}
+
+func (d *ChatDispatcher) Dispatch(update tgbotapi.Update) {
+	chatID, ok := updateChatID(update)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	queue, found := d.workers[chatID]
+	if !found {
+		queue = make(chan tgbotapi.Update, chatQueueSize)
+		d.workers[chatID] = queue
+		go d.work(queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case queue <- update:
+	default:
+		slog.Warn("Очередь чата переполнена, обновление пропущено", "chatID", chatID, "updateID", update.UpdateID)
+	}
+}
+
+func (d *ChatDispatcher) work(queue chan tgbotapi.Update) {
+	for update := range queue {
+		d.handle(update)
+	}
+}
+
+func updateChatID(update tgbotapi.Update) (int64, bool) {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID, true
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID, true
+	}
+	return 0, false
+}
+
+// sessionTTL сколько бот ждет ответ на вопрос, после этого сессия забывается
+const sessionTTL = 10 * time.Minute
+
+// session описывает вопрос, на который бот ждет ответ от пользователя в конкретном чате
+type session struct {
+	promptID  int
+	expiresAt time.Time
+	onReply   func(m *tgbotapi.Message)
+}
+
+// sessionKey у каждого участника группы своя сессия
+type sessionKey struct {
+	chatID int64
+	userID int64
+}
+
+type Sessions struct {
+	mu       sync.Mutex
+	sessions map[sessionKey]*session
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{sessions: map[sessionKey]*session{}}
+}
+
+func (s *Sessions) Get(chatID, userID int64) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey{chatID: chatID, userID: userID}
+	sess := s.sessions[key]
+	if sess != nil && time.Now().After(sess.expiresAt) {
+		delete(s.sessions, key)
+		return nil
+	}
+	return sess
+}
+
+func (s *Sessions) Set(chatID, userID int64, sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, old := range s.sessions {
+		if now.After(old.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	sess.expiresAt = now.Add(sessionTTL)
+	s.sessions[sessionKey{chatID: chatID, userID: userID}] = sess
+}
+
+func (s *Sessions) End(chatID, userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionKey{chatID: chatID, userID: userID})
+}
+
+// handleReply передает сообщение активной сессии автора. Если он пишет что-то
+// кроме ответа на вопрос бота, сессия отменяется и сообщение обрабатывается как обычно.
+// У других участников чата свои сессии, их сообщения эту не затрагивают.
+func (b *Bot) handleReply(m *tgbotapi.Message) bool {
+	actor := messageActor(m)
+	sess := b.sessions.Get(actor.ChatID, actor.UserID)
+	if sess == nil {
+		return false
+	}
+
+	if m.ReplyToMessage == nil || m.ReplyToMessage.MessageID != sess.promptID {
+		b.sessions.End(actor.ChatID, actor.UserID)
+		b.MessageWithReplyMarkup(m.Chat.ID, "Операция была отменена", actionButtons)
+		return false
+	}
+
+	sess.onReply(m)
+	return true
+}
+
+func (b *Bot) ask(actor Actor, question string, onReply func(m *tgbotapi.Message)) {
+	msg := tgbotapi.NewMessage(actor.ChatID, question)
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
+	asked, err := b.sender.Send(msg)
+	if err != nil {
+		slog.Error("Не удалось отправить вопрос", "chatID", actor.ChatID, "error", err)
+		return
+	}
+
+	b.sessions.Set(actor.ChatID, actor.UserID, &session{promptID: asked.MessageID, onReply: onReply})
+}
+
+// askNumber спрашивает число от 1 до mx и передает его в next. При неверном вводе вопрос остается активным
+func (b *Bot) askNumber(actor Actor, question string, mx int64, next func(n int64)) {
+	b.ask(actor, question, func(m *tgbotapi.Message) {
+		n, err := strconv.ParseInt(strings.TrimSpace(m.Text), 10, 64)
+		if err != nil || n <= 0 || n > mx {
+			b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Введи целое положительное число не больше %d", mx), actionButtons)
+			return
+		}
+		b.sessions.End(actor.ChatID, actor.UserID)
+		next(n)
+	})
+}
+
+func (b *Bot) askStrings(actor Actor, question string, next func(strs []string)) {
+	b.ask(actor, question, func(m *tgbotapi.Message) {
+		b.sessions.End(actor.ChatID, actor.UserID)
+		next(strings.Fields(m.Text))
+	})
+}
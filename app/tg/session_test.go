@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	s := NewSessions()
+	alice, bob := &session{promptID: 1}, &session{promptID: 2}
+	s.Set(10, 1, alice)
+	s.Set(10, 2, bob)
+
+	if got := s.Get(10, 1); got != alice {
+		t.Errorf("Get(alice) = %+v, want alice's session", got)
+	}
+	if got := s.Get(10, 2); got != bob {
+		t.Errorf("Get(bob) = %+v, want bob's session", got)
+	}
+	if got := s.Get(20, 1); got != nil {
+		t.Errorf("Get in another chat = %+v, want nil", got)
+	}
+
+	s.End(10, 2)
+	if s.Get(10, 2) != nil || s.Get(10, 1) != alice {
+		t.Error("End must remove only the given user's session")
+	}
+
+	alice.expiresAt = time.Now().Add(-time.Second)
+	if got := s.Get(10, 1); got != nil {
+		t.Errorf("Get of expired session = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertSilenceDuration на сколько глушит алерт кнопка в сообщении
+const alertSilenceDuration = time.Hour
+
+// activeSilences возвращает действующие silences; при ошибке хранилища алерты не глушатся
+func (b *Bot) activeSilences(now time.Time) []domain.Silence {
+	silences, err := b.silences.GetActiveSilences(now)
+	if err != nil {
+		slog.Error("Не удалось получить silences", "error", err)
+		return nil
+	}
+	return silences
+}
+
+// alertSilence глушит алерты с тем же alertname и подом в namespace алерта
+func (b *Bot) alertSilence(actor Actor, a domain.Alert) (string, error) {
+	matchers := map[string]string{
+		"alertname": a.Labels.Alertname,
+		"namespace": a.Namespace,
+	}
+	switch {
+	case a.GroupLeaderID() != 0 && a.Deployment != "":
+		// Кнопка группы глушит все поды deployment
+		matchers["deployment"] = a.Deployment
+	case a.GroupLeaderID() == 0 && a.Labels.Pod != "":
+		matchers["pod"] = a.Labels.Pod
+	}
+
+	s, err := b.createSilence(actor, matchers, alertSilenceDuration)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("алерт %s заглушен до %s", a.Labels.Alertname, s.ExpiresAt.Local().Format("15:04")), nil
+}
+
+func (b *Bot) createSilence(actor Actor, matchers map[string]string, d time.Duration) (domain.Silence, error) {
+	now := time.Now()
+	s := domain.Silence{
+		Matchers:      matchers,
+		CreatedBy:     actor.UserID,
+		CreatedByName: actor.UserName,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(d),
+	}
+
+	var err error
+	s.ID, err = b.silences.CreateSilence(s)
+	if err != nil {
+		return s, fmt.Errorf("не удалось сохранить silence: %w", err)
+	}
+	slog.Info("Создан silence", "id", s.ID, "matchers", matchers, "until", s.ExpiresAt, "by", actor.UserID)
+	return s, nil
+}
+
+// addSilence создает silence или окно обслуживания: /silence <длительность> <метка>=<значение>...
+func (b *Bot) addSilence(actor Actor, args []string) {
+	usage := "Использование: /silence <длительность, например 30m или 2h> namespace=<namespace> [alertname=<имя>] [pod=<под>] [<метка>=<значение>...]"
+	if len(args) < 2 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	d, err := time.ParseDuration(args[0])
+	if err != nil || d <= 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+
+	matchers := make(map[string]string, len(args)-1)
+	for _, arg := range args[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" || value == "" {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		matchers[name] = value
+	}
+
+	ns := matchers["namespace"]
+	if ns == "" {
+		b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+		return
+	}
+	if !slices.Contains(b.subs.Namespaces(actor.ChatID), ns) {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан на namespace "+ns, actionButtons)
+		return
+	}
+
+	s, err := b.createSilence(actor, matchers, d)
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось создать silence", actionButtons)
+		return
+	}
+	b.MessageWithReplyMarkup(actor.ChatID, "Silence создан: "+s.String(), actionButtons)
+}
+
+// showSilences показывает действующие silences для namespaces чата
+func (b *Bot) showSilences(actor Actor) {
+	namespaces := b.subs.Namespaces(actor.ChatID)
+
+	var out []string
+	for _, s := range b.activeSilences(time.Now()) {
+		if slices.Contains(namespaces, s.Matchers["namespace"]) {
+			out = append(out, s.String())
+		}
+	}
+	if len(out) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Активных silences нет", actionButtons)
+		return
+	}
+	b.MessageWithReplyMarkup(actor.ChatID, "Активные silences:\n"+strings.Join(out, "\n"), actionButtons)
+}
+
+// removeSilence досрочно снимает silence: /unsilence <id>
+func (b *Bot) removeSilence(actor Actor, args []string) {
+	if len(args) != 1 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Использование: /unsilence <id>", actionButtons)
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		b.MessageWithReplyMarkup(actor.ChatID, "Использование: /unsilence <id>", actionButtons)
+		return
+	}
+
+	now := time.Now()
+	namespaces := b.subs.Namespaces(actor.ChatID)
+	idx := slices.IndexFunc(b.activeSilences(now), func(s domain.Silence) bool {
+		return s.ID == id && slices.Contains(namespaces, s.Matchers["namespace"])
+	})
+	if idx < 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Активный silence #%d не найден", id), actionButtons)
+		return
+	}
+
+	if err = b.silences.ExpireSilence(id, now); err != nil {
+		slog.Error("Не удалось снять silence", "id", id, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось снять silence", actionButtons)
+		return
+	}
+	slog.Info("Silence снят", "id", id, "by", actor.UserID)
+	b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Silence #%d снят", id), actionButtons)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStatsPeriod = 7 * 24 * time.Hour
+	statsTopAlerts     = 3
+)
+
+// showStats показывает статистику инцидентов: /stats [период, например 24h] [deployment] [namespace]
+func (b *Bot) showStats(actor Actor, args []string) {
+	usage := "Использование: /stats [период, например 24h или 168h] [deployment] [namespace]"
+
+	period := defaultStatsPeriod
+	groupBy := domain.StatsByNamespace
+	namespaces := b.subs.Namespaces(actor.ChatID)
+	var only []string
+
+	for _, arg := range args {
+		if d, err := time.ParseDuration(arg); err == nil && d > 0 {
+			period = d
+			continue
+		}
+		if arg == domain.StatsByDeployment {
+			groupBy = domain.StatsByDeployment
+			continue
+		}
+		if !slices.Contains(namespaces, arg) {
+			b.MessageWithReplyMarkup(actor.ChatID, usage, actionButtons)
+			return
+		}
+		only = append(only, arg)
+	}
+	if len(only) > 0 {
+		namespaces = only
+	}
+	if len(namespaces) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан ни на один namespace, используйте /start", actionButtons)
+		return
+	}
+
+	now := time.Now()
+	alerts, err := b.repo.FindAlerts(domain.AlertFilter{
+		Namespaces: namespaces,
+		From:       now.Add(-period),
+		To:         now,
+		Limit:      domain.NoAlertLimit,
+	})
+	if err != nil {
+		slog.Error("Не удалось получить алерты для статистики", "chatID", actor.ChatID, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, "Не удалось посчитать статистику", actionButtons)
+		return
+	}
+
+	stats := domain.CalculateStats(alerts, groupBy, statsTopAlerts)
+	if len(stats) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("За %s инцидентов не было", period), actionButtons)
+		return
+	}
+
+	lines := make([]string, len(stats))
+	for i, s := range stats {
+		lines[i] = s.String()
+	}
+	b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Статистика за %s:\n", period)+strings.Join(lines, "\n"), actionButtons)
+}
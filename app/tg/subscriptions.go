@@ -0,0 +1,81 @@
+package main
+
+import (
+	"hack-a-tone/internal/core/port"
+	"slices"
+	"sync"
+)
+
+// Subscriptions держит в памяти связи чатов и namespace, синхронизируя их с хранилищем
+type Subscriptions struct {
+	mu                  sync.RWMutex
+	repo                port.SubscriptionRepo
+	chatIDToNamespaces  map[int64][]string
+	namespacesToChatIDs map[string][]int64
+}
+
+func NewSubscriptions(repo port.SubscriptionRepo) (*Subscriptions, error) {
+	saved, err := repo.GetSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscriptions{
+		repo:                repo,
+		chatIDToNamespaces:  map[int64][]string{},
+		namespacesToChatIDs: map[string][]int64{},
+	}
+	for chatID, namespaces := range saved {
+		s.add(chatID, namespaces)
+	}
+
+	return s, nil
+}
+
+func (s *Subscriptions) Namespaces(chatID int64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.chatIDToNamespaces[chatID])
+}
+
+func (s *Subscriptions) ChatIDs(namespace string) []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.namespacesToChatIDs[namespace])
+}
+
+func (s *Subscriptions) Add(chatID int64, namespaces []string) error {
+	if err := s.repo.AddNamespaces(chatID, namespaces); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(chatID, namespaces)
+	return nil
+}
+
+func (s *Subscriptions) Remove(chatID int64, namespaces []string) error {
+	if err := s.repo.RemoveNamespaces(chatID, namespaces); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ns := range namespaces {
+		s.chatIDToNamespaces[chatID] = slices.DeleteFunc(s.chatIDToNamespaces[chatID], func(n string) bool { return n == ns })
+		s.namespacesToChatIDs[ns] = slices.DeleteFunc(s.namespacesToChatIDs[ns], func(id int64) bool { return id == chatID })
+	}
+	return nil
+}
+
+func (s *Subscriptions) add(chatID int64, namespaces []string) {
+	for _, ns := range namespaces {
+		if !slices.Contains(s.chatIDToNamespaces[chatID], ns) {
+			s.chatIDToNamespaces[chatID] = append(s.chatIDToNamespaces[chatID], ns)
+		}
+		if !slices.Contains(s.namespacesToChatIDs[ns], chatID) {
+			s.namespacesToChatIDs[ns] = append(s.namespacesToChatIDs[ns], chatID)
+		}
+	}
+}
@@ -15,8 +15,8 @@ import (
 	"image/png"
 	"log/slog"
 	"os"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 )
 
@@ -44,54 +44,77 @@ var actionButtons = tgbotapi.NewReplyKeyboard(
 
 type Bot struct {
 	bot           *tgbotapi.BotAPI
+	sender        *Sender
 	k8sController port.KubeController
-	userLogged    map[string]bool
-	usersData     map[string]string
 	repo          port.AlertRepo
+	subs          *Subscriptions
+	users         port.UserRepo
+	auditLog      port.AuditRepo
+	escalations   port.EscalationRepo
+	onCallRepo    port.OnCallRepo
+	silences      port.SilenceRepo
+	digests       port.DigestRepo
+	grouping      domain.AlertGrouping
+	groupLocks    keyedLocks
+	sessions      *Sessions
+	handlers      map[string]func(*tgbotapi.CallbackQuery)
 }
 
-func NewBot(token string, k8sController port.KubeController, db port.AlertRepo) *Bot {
+// Stores хранилища, с которыми работает бот
+type Stores struct {
+	Alerts        port.AlertRepo
+	Subscriptions port.SubscriptionRepo
+	Users         port.UserRepo
+	Audit         port.AuditRepo
+	Escalations   port.EscalationRepo
+	OnCall        port.OnCallRepo
+	Silences      port.SilenceRepo
+	Digests       port.DigestRepo
+}
+
+// Options настройки отправки сообщений и группировки алертов
+type Options struct {
+	Sender   SenderConfig
+	Grouping domain.AlertGrouping
+}
+
+func NewBot(token string, k8sController port.KubeController, stores Stores, opts Options) *Bot {
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		slog.Error("Не удалось создать бота", "error", err)
 		return nil
 	}
 
-	return &Bot{
-		bot:           bot,
-		k8sController: k8sController,
-		repo:          db,
+	subs, err := NewSubscriptions(stores.Subscriptions)
+	if err != nil {
+		slog.Error("Не удалось загрузить подписки", "error", err)
+		return nil
 	}
-}
 
-func WaitNumber(b *Bot, updates *tgbotapi.UpdatesChannel, chatID int64, start string, mx int64) int64 {
-	msg := tgbotapi.NewMessage(chatID, start)
-	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
-	askedMessage, _ := b.bot.Send(msg)
-
-	for listen := range *updates {
-		listenMessage := listen.Message
-		if listenMessage != nil && listenMessage.ReplyToMessage != nil &&
-			listenMessage.ReplyToMessage.MessageID == askedMessage.MessageID {
-			i64, err := strconv.ParseInt(listenMessage.Text, 10, 64)
-			if err != nil || i64 <= 0 || i64 > mx {
-				b.MessageWithReplyMarkup(listen.Message.Chat.ID,
-					fmt.Sprintf("Введи целое положительное число не больше %d", mx), actionButtons)
-				continue
-			}
-			return i64
-		} else {
-			b.MessageWithReplyMarkup(listen.Message.Chat.ID, "Операция была отменена", actionButtons)
-			return -1
-		}
-	}
-	return -1
+	b := &Bot{
+		bot:           bot,
+		sender:        NewSender(bot, opts.Sender),
+		k8sController: k8sController,
+		repo:          stores.Alerts,
+		subs:          subs,
+		users:         stores.Users,
+		auditLog:      stores.Audit,
+		escalations:   stores.Escalations,
+		onCallRepo:    stores.OnCall,
+		silences:      stores.Silences,
+		digests:       stores.Digests,
+		grouping:      opts.Grouping,
+		sessions:      NewSessions(),
+	}
+	b.handlers = b.callbackHandlers()
+
+	return b
 }
 
 func getPodsString(b *Bot, ns string) (string, []string, error) {
 	pods, err := b.k8sController.GetAllPods(context.Background(), ns)
 	if err != nil {
-		slog.Error("Не удалось получить все ревизии", err)
+		slog.Error("Не удалось получить все поды", "error", err)
 		return "", []string{}, err
 	} else {
 		out := make([]string, len(pods.Items))
@@ -108,7 +131,7 @@ func getPodsString(b *Bot, ns string) (string, []string, error) {
 func getRevisionsString(b *Bot, ns string, depl string) (string, []string, error) {
 	revs, err := b.k8sController.GetAvailableRevisions(context.Background(), depl, ns)
 	if err != nil {
-		slog.Error("Не удалось получить все ревизии", err)
+		slog.Error("Не удалось получить все ревизии", "error", err)
 		return "", []string{}, err
 	} else {
 		sort.Sort(sort.Reverse(sort.StringSlice(revs)))
@@ -121,9 +144,9 @@ func getRevisionsString(b *Bot, ns string, depl string) (string, []string, error
 	}
 }
 
-func getNamespacesString(chatID int64) string {
-	out := make([]string, len(ChatIDToNamespaces[chatID]))
-	for i, ns := range ChatIDToNamespaces[chatID] {
+func getNamespacesString(namespaces []string) string {
+	out := make([]string, len(namespaces))
+	for i, ns := range namespaces {
 		out[i] = fmt.Sprintf("%d) %s", i+1, ns)
 	}
 	str := strings.Join(out, "\n")
@@ -133,7 +156,7 @@ func getNamespacesString(chatID int64) string {
 func getDeploymentsString(b *Bot, ns string) (string, []string, error) {
 	deployments, err := b.k8sController.GetDeployments(context.Background(), ns)
 	if err != nil {
-		slog.Error("Не удалось получить все деплои", err)
+		slog.Error("Не удалось получить все деплои", "error", err)
 		return "", []string{}, err
 	} else {
 		deps := make([]string, len(deployments.Items))
@@ -152,221 +175,237 @@ type ActionData struct {
 	Revision  string `json:"r"`
 	Deploy    string `json:"d"`
 	Namespace string `json:"n"`
+	Alert     int64  `json:"a,omitempty"`
+	// Replicas ожидаемое количество подов, чтобы повторное нажатие не масштабировало еще раз
+	Replicas int32 `json:"c,omitempty"`
 }
 
 func mustJSON(v interface{}) string {
 	b, err := json.Marshal(v)
 	if err != nil {
-		slog.Error("json marshal failed: %v", err)
+		slog.Error("json marshal failed", "error", err)
 	}
 	return string(b)
 }
 
-var ChatIDToNamespaces = map[int64][]string{}
-var NamespacesToChatIDs = map[string][]int64{}
-
-func WaitStrings(b *Bot, updates *tgbotapi.UpdatesChannel, chatID int64, startMsg string) []string {
-	msg := tgbotapi.NewMessage(chatID, startMsg)
-	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
-	askedMessage, _ := b.bot.Send(msg)
-
-	for listen := range *updates {
-		listenMessage := listen.Message
-		if listenMessage != nil && listenMessage.ReplyToMessage != nil &&
-			listenMessage.ReplyToMessage.MessageID == askedMessage.MessageID {
-			return strings.Split(strings.TrimSpace(listenMessage.Text), " ")
-		} else {
-			return []string{}
-		}
-	}
-	return []string{}
-}
-
 func (b *Bot) ValidateNamespaces(ns []string) (res []string) {
 	for _, n := range ns {
-		slog.Info("Trying to get deployments for", ns)
+		slog.Info("Trying to get deployments for", "namespace", n)
 		_, err := b.k8sController.GetDeployments(context.Background(), n)
 		if err == nil {
 			res = append(res, n)
 		} else {
-			slog.Info("ошибка при валидации namespace:", err)
+			slog.Info("ошибка при валидации namespace", "namespace", n, "error", err)
 		}
 	}
 	return
 }
 
-func (b *Bot) RegisterNamespaces(chatID int64, ch *tgbotapi.UpdatesChannel) {
-	slog.Info("Starting register namespaces to chat -", chatID)
-	strs := WaitStrings(b, ch, chatID,
+func (b *Bot) RegisterNamespaces(actor Actor) {
+	slog.Info("Starting register namespaces", "chatID", actor.ChatID)
+	b.askStrings(actor,
 		"Привет! Я создан для того, чтобы помогать быстрее реагировать на аварийные события в Kubernetes. "+
-			"Введи через пробел названия неймспейсов для отслеживания")
-	if len(strs) != 0 {
-		slog.Info("Got not empty namespaces list to register chat with ID", chatID)
-		var msgStr string
-		vld := b.ValidateNamespaces(strs)
-		if len(vld) != len(strs) {
-			slog.Info("Some namespaces didnt pass validation:", "passed", vld, "all", strs)
-			msgStr = "Что-то не сошлось, с ними все ок - " + strings.Join(vld, " ") + ", а пришло - " + strings.Join(strs, " ") + "\n"
-		}
-
-		// todo: проверить что существует
-		ChatIDToNamespaces[chatID] = strs
-		for _, str := range strs {
-			a := NamespacesToChatIDs[str]
-			a = append(a, chatID)
-			NamespacesToChatIDs[str] = a
-		}
-		msg := tgbotapi.NewMessage(chatID, msgStr+fmt.Sprintf("Namespaces: %s успешно зарегистрированы!", strs))
-		msg.ReplyMarkup = actionButtons
-		b.bot.Send(msg)
-		slog.Info("New chat registered", "chatID", chatID, "namespaces", strs)
-	} else {
-		msg := tgbotapi.NewMessage(chatID, "Операция отменена")
-		msg.ReplyMarkup = actionButtons
-		b.bot.Send(msg)
-	}
-}
+			"Введи через пробел названия неймспейсов для отслеживания",
+		func(strs []string) {
+			if len(strs) == 0 {
+				b.MessageWithReplyMarkup(actor.ChatID, "Операция отменена", actionButtons)
+				return
+			}
 
-func (b *Bot) start() {
-	// Set update timeout
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+			slog.Info("Got not empty namespaces list to register chat", "chatID", actor.ChatID)
+			var msgStr string
+			vld := b.ValidateNamespaces(strs)
+			if len(vld) != len(strs) {
+				slog.Info("Some namespaces didnt pass validation:", "passed", vld, "all", strs)
+				msgStr = "Что-то не сошлось, с ними все ок - " + strings.Join(vld, " ") + ", а пришло - " + strings.Join(strs, " ") + "\n"
+			}
+			if len(vld) == 0 {
+				b.MessageWithReplyMarkup(actor.ChatID, msgStr+"Ни один namespace не найден", actionButtons)
+				return
+			}
 
-	// Get updates from bot
-	updates, _ := b.bot.GetUpdatesChan(u)
+			if err := b.subs.Add(actor.ChatID, vld); err != nil {
+				slog.Error("Не удалось сохранить подписки", "chatID", actor.ChatID, "error", err)
+				b.MessageWithReplyMarkup(actor.ChatID, "Не удалось сохранить namespaces", actionButtons)
+				return
+			}
+			b.MessageWithReplyMarkup(actor.ChatID, msgStr+fmt.Sprintf("Namespaces: %s успешно зарегистрированы!", vld), actionButtons)
+			slog.Info("New chat registered", "chatID", actor.ChatID, "namespaces", vld)
+		})
+}
 
-	handlers := map[string]func(*tgbotapi.BotAPI, *tgbotapi.CallbackQuery){
-		"roll_yes": func(api *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+func (b *Bot) callbackHandlers() map[string]func(*tgbotapi.CallbackQuery) {
+	return map[string]func(*tgbotapi.CallbackQuery){
+		"roll_yes": func(cq *tgbotapi.CallbackQuery) {
 			var data ActionData
 			json.Unmarshal([]byte(cq.Data), &data)
 
 			err := b.k8sController.SetRevision(context.Background(), data.Deploy, data.Namespace, data.Revision)
+			b.audit(callbackActor(cq), domain.ActionRollback, data.Namespace, data.Deploy, "revision="+data.Revision, err)
 			if err != nil {
 				str := "Не получилось установить ревизию"
-				MessageWithReplyMarkup(api, cq.Message.Chat.ID, str, actionButtons)
-				slog.Error(str, err)
+				slog.Error(str, "error", err)
+				b.finishCallback(cq, str+" ❌")
+				return
 			}
 
-			edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, "Ревизия была установлена ✅")
-			edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
-			api.Send(edit)
-			api.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, ""))
-			MessageWithReplyMarkup(api, cq.Message.Chat.ID, "Выберите следующее действие", actionButtons)
+			b.finishCallback(cq, "Ревизия была установлена ✅")
 		},
-		"roll_no": func(api *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
-			edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, "Ревизия не была установлена ❌")
-			edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
-			api.Send(edit)
-			api.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, ""))
-			MessageWithReplyMarkup(api, cq.Message.Chat.ID, "Выберите следующее действие", actionButtons)
+		"roll_no": func(cq *tgbotapi.CallbackQuery) {
+			b.finishCallback(cq, "Ревизия не была установлена ❌")
 		},
-		"rs_yes": func(api *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
+		"rs_yes": func(cq *tgbotapi.CallbackQuery) {
 			var data ActionData
 			json.Unmarshal([]byte(cq.Data), &data)
 
 			err := b.k8sController.RestartDeployment(context.Background(), data.Deploy, data.Namespace)
+			b.audit(callbackActor(cq), domain.ActionRestartDeployment, data.Namespace, data.Deploy, "", err)
 			if err != nil {
 				str := "Не получилось перезапустить deployment"
-				MessageWithReplyMarkup(api, cq.Message.Chat.ID, str, actionButtons)
-				slog.Error(str, err)
+				slog.Error(str, "error", err)
+				b.finishCallback(cq, str+" ❌")
+				return
 			}
 
-			edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, "Deployment был перезапущен ✅")
-			edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
-			api.Send(edit)
-			api.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, ""))
-			MessageWithReplyMarkup(api, cq.Message.Chat.ID, "Выберите следующее действие", actionButtons)
+			b.finishCallback(cq, "Deployment был перезапущен ✅")
+		},
+		"rs_no": func(cq *tgbotapi.CallbackQuery) {
+			b.finishCallback(cq, "Deployment не был перезапущен ❌")
 		},
-		"rs_no": func(api *tgbotapi.BotAPI, cq *tgbotapi.CallbackQuery) {
-			edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, "Deployment не был перезапущен ❌")
-			edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
-			api.Send(edit)
-			api.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, ""))
-			MessageWithReplyMarkup(api, cq.Message.Chat.ID, "Выберите следующее действие", actionButtons)
+		"al_ack":      b.ackAlert,
+		"al_pod":      b.confirmAlertAction(b.askRestartPod),
+		"al_pod_yes":  b.confirmedAlertAction(b.alertRestartPod),
+		"al_dep":      b.confirmAlertAction(b.askRestartDeployment),
+		"al_dep_yes":  b.confirmedAlertAction(b.alertRestartDeployment),
+		"al_roll":     b.confirmAlertAction(b.askRollback),
+		"al_roll_yes": b.confirmedAlertAction(b.alertRollback),
+		"al_up":       b.confirmAlertAction(b.askScaleUp),
+		"al_up_yes":   b.confirmedAlertAction(b.alertScaleUp),
+		"al_no": func(cq *tgbotapi.CallbackQuery) {
+			b.finishCallback(cq, "Действие отменено ❌")
 		},
+		"al_sil": b.alertAction(b.alertSilence),
 	}
+}
 
-	for update := range updates {
-		if update.Message == nil {
-			if cq := update.CallbackQuery; cq != nil {
-				var data ActionData
-				json.Unmarshal([]byte(cq.Data), &data)
-				if handler, found := handlers[data.Key]; found {
-					handler(b.bot, cq) // вызываем нужный обработчик
-				} else {
-					// необработанный callbackData
-					b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, "Неизвестная кнопка"))
-				}
+// finishCallback заменяет текст сообщения с кнопками подтверждения на результат действия
+func (b *Bot) finishCallback(cq *tgbotapi.CallbackQuery, result string) {
+	edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, result)
+	edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	b.sender.Send(edit)
+	b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, ""))
+	b.MessageWithReplyMarkup(cq.Message.Chat.ID, "Выберите следующее действие", actionButtons)
+}
+
+// start обрабатывает обновления Telegram до отмены ctx
+func (b *Bot) start(ctx context.Context) {
+	// Set update timeout
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+
+	// Get updates from bot
+	updates, err := b.bot.GetUpdatesChan(u)
+	if err != nil {
+		slog.Error("Не удалось получить канал обновлений", "error", err)
+		return
+	}
+
+	dispatcher := NewChatDispatcher(b.handleUpdate)
+	for {
+		select {
+		case <-ctx.Done():
+			b.bot.StopReceivingUpdates()
+			return
+		case update := <-updates:
+			dispatcher.Dispatch(update)
+		}
+	}
+}
+
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	if cq := update.CallbackQuery; cq != nil {
+		var data ActionData
+		json.Unmarshal([]byte(cq.Data), &data)
+		if handler, found := b.handlers[data.Key]; found {
+			if ok, why := b.authorize(callbackActor(cq), callbackRoles[data.Key]); !ok {
+				b.bot.AnswerCallbackQuery(tgbotapi.NewCallbackWithAlert(cq.ID, why))
+				return
 			}
-			continue
+			handler(cq) // вызываем нужный обработчик
+		} else {
+			// необработанный callbackData
+			b.bot.AnswerCallbackQuery(tgbotapi.NewCallback(cq.ID, "Неизвестная кнопка"))
 		}
+		return
+	}
 
-		currentMessage := update.Message
-		currentChatID := currentMessage.Chat.ID
+	currentMessage := update.Message
+	if currentMessage == nil {
+		return
+	}
+	actor := messageActor(currentMessage)
 
-		switch currentMessage.Text {
-		case "/start":
-			b.RegisterNamespaces(currentChatID, &updates)
+	if b.handleReply(currentMessage) {
+		return
+	}
 
-		case SeeLastIncidents:
-			ask1 := "Введите количество последних инцидентов, которые вы хотите посмотреть\n"
-			incidentsNum := WaitNumber(b, &updates, update.Message.Chat.ID, ask1, 20)
-			if incidentsNum < 1 {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите положительное число меньше 20")
-				msg.ReplyMarkup = actionButtons
-				b.bot.Send(msg)
-				continue
-			}
-			alerts, err := b.repo.GetLastNAlerts(int(incidentsNum), ChatIDToNamespaces[update.Message.Chat.ID])
-			if err != nil {
-				slog.Error("getting last n alerts from repo:", "error", err)
-			}
+	if currentMessage.IsCommand() {
+		b.handleCommand(currentMessage)
+		return
+	}
 
-			var astr []string
-			for _, a := range alerts {
-				astr = append(astr, a.String())
-			}
-			a := strings.Join(astr, "\n")
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, a)
-			b.bot.Send(msg)
-
-		case SeeLastIncidents:
-			ask1 := "Введите количество последних инцидентов, которые вы хотите посмотреть\n"
-			incidentsNum := WaitNumber(b, &updates, update.Message.Chat.ID, ask1, 20)
-			if incidentsNum < 1 {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите положительное число меньше 20")
-				msg.ReplyMarkup = actionButtons
-				b.bot.Send(msg)
-				continue
-			}
-			alerts, err := b.repo.GetLastNAlerts(int(incidentsNum), ChatIDToNamespaces[update.Message.Chat.ID])
-			if err != nil {
-				slog.Error("getting last n alerts from repo:", "error", err)
-			}
+	need, found := buttonRoles[currentMessage.Text]
+	if !found || !b.allowed(actor, need) {
+		return
+	}
 
-			var astr []string
-			for _, a := range alerts {
-				astr = append(astr, a.String())
-			}
-			a := strings.Join(astr, "\n")
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, a)
-			b.bot.Send(msg)
+	switch currentMessage.Text {
+	case SeeLastIncidents:
+		b.showLastIncidents(actor)
+	case RollbackVersion:
+		b.rollbackVersion(actor)
+	case ViewData:
+		b.viewData(actor)
+	case ChangePods:
+		b.changePods(actor)
+	case RestartDeployment:
+		b.restartDeployment(actor)
+	case RestartPod:
+		b.restartPod(actor)
+	default:
+	}
+}
 
-		case RollbackVersion:
-			ns, depl, status := b.AskNsAndDeploy(&updates, currentChatID)
-			if status != Ok {
-				continue
-			}
+func (b *Bot) showLastIncidents(actor Actor) {
+	ask := "Введите количество последних инцидентов, которые вы хотите посмотреть\n"
+	b.askNumber(actor, ask, 20, func(incidentsNum int64) {
+		alerts, err := b.repo.GetLastNAlerts(int(incidentsNum), b.subs.Namespaces(actor.ChatID))
+		if err != nil {
+			slog.Error("getting last n alerts from repo:", "error", err)
+		}
 
-			askRevs := "Укажите номер ревизии:\n"
-			revsString, revs, err := getRevisionsString(b, ns, depl)
-			if err != nil {
-				str := "Не получилось получить номер ревизии"
-				slog.Error(str, err)
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
-				continue
-			}
-			revId := WaitNumber(b, &updates, currentChatID, askRevs+revsString, int64(len(revs)))
+		var astr []string
+		for _, a := range alerts {
+			astr = append(astr, a.String())
+		}
+		if len(astr) == 0 {
+			astr = append(astr, "Инцидентов не найдено")
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, strings.Join(astr, "\n"), actionButtons)
+	})
+}
+
+func (b *Bot) rollbackVersion(actor Actor) {
+	b.AskNsAndDeploy(actor, func(ns, depl string) {
+		askRevs := "Укажите номер ревизии:\n"
+		revsString, revs, err := getRevisionsString(b, ns, depl)
+		if err != nil {
+			str := "Не получилось получить номер ревизии"
+			slog.Error(str, "error", err)
+			b.MessageWithReplyMarkup(actor.ChatID, str, actionButtons)
+			return
+		}
+		b.askNumber(actor, askRevs+revsString, int64(len(revs)), func(revId int64) {
 			revision := revs[revId-1]
 
 			dataYes := ActionData{
@@ -387,185 +426,263 @@ func (b *Bot) start() {
 				tgbotapi.NewInlineKeyboardRow(checkBtn, crossBtn),
 			)
 			askStr := fmt.Sprintf("Восстановить ревизию %s у deployment %s?", revision, depl)
-			b.MessageWithReplyMarkup(currentChatID, askStr, keyboard)
+			b.MessageWithReplyMarkup(actor.ChatID, askStr, keyboard)
+		})
+	})
+}
 
-		case ViewData:
-			photoMsg := GetPhotoMessageForGrafana(update.Message.Chat.ID)
-			deployStatus, err := b.k8sController.StatusAll(context.Background())
-			if err != nil {
-				str := "Не удалось получить общий статус"
-				slog.Error(str, err)
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
-			} else {
-				msg := tgbotapi.NewMessage(currentChatID, PrettyPrintStatus(deployStatus))
-				msg.ReplyMarkup = actionButtons
-				msg.ParseMode = tgbotapi.ModeMarkdown
-				b.bot.Send(msg)
-				b.bot.Send(photoMsg)
-			}
+func (b *Bot) viewData(actor Actor) {
+	photoMsg := GetPhotoMessageForGrafana(actor.ChatID)
+	deployStatus, err := b.k8sController.StatusAll(context.Background())
+	if err != nil {
+		str := "Не удалось получить общий статус"
+		slog.Error(str, "error", err)
+		b.MessageWithReplyMarkup(actor.ChatID, str, actionButtons)
+		return
+	}
 
-		case ChangePods:
-			ns, depl, status := b.AskNsAndDeploy(&updates, currentChatID)
-			if status != Ok {
-				continue
-			}
-			curCount, err := b.k8sController.GetPodsCount(context.Background(), ns, depl)
-			if err != nil {
-				str := "Не удалось получить количество подов"
-				slog.Error(str, err)
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
-				continue
-			}
-			askStr := fmt.Sprintf("Введите новое количество подов (сейчас %d)", curCount)
-			number := WaitNumber(b, &updates, currentChatID, askStr, 30)
-			if number == -1 {
-				continue
-			}
-			err = b.k8sController.ScalePod(context.Background(), depl, ns, int32(number))
+	msg := tgbotapi.NewMessage(actor.ChatID, PrettyPrintStatus(deployStatus))
+	msg.ReplyMarkup = actionButtons
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	b.sender.Send(msg)
+	if photoMsg != nil {
+		b.sender.Send(photoMsg)
+	}
+}
+
+func (b *Bot) changePods(actor Actor) {
+	b.AskNsAndDeploy(actor, func(ns, depl string) {
+		curCount, err := b.k8sController.GetPodsCount(context.Background(), ns, depl)
+		if err != nil {
+			str := "Не удалось получить количество подов"
+			slog.Error(str, "error", err)
+			b.MessageWithReplyMarkup(actor.ChatID, str, actionButtons)
+			return
+		}
+		askStr := fmt.Sprintf("Введите новое количество подов (сейчас %d)", curCount)
+		b.askNumber(actor, askStr, maxReplicas, func(number int64) {
+			err := b.k8sController.ScalePod(context.Background(), depl, ns, int32(number))
+			b.audit(actor, domain.ActionScale, ns, depl, fmt.Sprintf("replicas=%d->%d", curCount, number), err)
 			if err != nil {
 				str := "Не удалось изменить количество подов"
-				slog.Error(str, err)
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
+				slog.Error(str, "error", err)
+				b.MessageWithReplyMarkup(actor.ChatID, str, actionButtons)
 			} else {
 				str := fmt.Sprintf("Новое количество подов: %d", number)
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
-			}
-
-		case RestartDeployment:
-			ns, depl, status := b.AskNsAndDeploy(&updates, currentChatID)
-			if status != Ok {
-				continue
+				b.MessageWithReplyMarkup(actor.ChatID, str, actionButtons)
 			}
+		})
+	})
+}
 
-			dataYes := ActionData{Key: "rs_yes", Revision: "1", Deploy: depl, Namespace: ns}
-			dataNo := ActionData{Key: "rs_no", Revision: "1", Deploy: "1", Namespace: "1"}
-			checkBtn := tgbotapi.NewInlineKeyboardButtonData("✅", mustJSON(dataYes))
-			crossBtn := tgbotapi.NewInlineKeyboardButtonData("❌", mustJSON(dataNo))
-			keyboard := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(checkBtn, crossBtn),
-			)
-			str := fmt.Sprintf("Перзапустить deployment %s?", depl)
-			b.MessageWithReplyMarkup(currentChatID, str, keyboard)
+func (b *Bot) restartDeployment(actor Actor) {
+	b.AskNsAndDeploy(actor, func(ns, depl string) {
+		dataYes := ActionData{Key: "rs_yes", Revision: "1", Deploy: depl, Namespace: ns}
+		dataNo := ActionData{Key: "rs_no", Revision: "1", Deploy: "1", Namespace: "1"}
+		checkBtn := tgbotapi.NewInlineKeyboardButtonData("✅", mustJSON(dataYes))
+		crossBtn := tgbotapi.NewInlineKeyboardButtonData("❌", mustJSON(dataNo))
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(checkBtn, crossBtn),
+		)
+		str := fmt.Sprintf("Перзапустить deployment %s?", depl)
+		b.MessageWithReplyMarkup(actor.ChatID, str, keyboard)
+	})
+}
 
-		case RestartPod:
-			ns, status := b.AskNamespace(&updates, currentChatID)
-			if status != Ok {
-				continue
-			}
-			pod, status := b.AskPod(&updates, currentChatID, ns)
-			if status != Ok {
-				continue
-			}
+func (b *Bot) restartPod(actor Actor) {
+	b.AskNamespace(actor, func(ns string) {
+		b.AskPod(actor, ns, func(pod string) {
 			err := b.k8sController.RestartPod(context.Background(), ns, pod)
+			b.audit(actor, domain.ActionRestartPod, ns, "", "pod="+pod, err)
 			if err != nil {
-				slog.Error("Can not restart pod", err)
-				str := fmt.Sprintf("Не получилось перезапустить под")
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
+				slog.Error("Can not restart pod", "error", err)
+				b.MessageWithReplyMarkup(actor.ChatID, "Не получилось перезапустить под", actionButtons)
 			} else {
-				str := fmt.Sprintf("Под был перезапущен")
-				b.MessageWithReplyMarkup(currentChatID, str, actionButtons)
+				b.MessageWithReplyMarkup(actor.ChatID, "Под был перезапущен", actionButtons)
 			}
-		default:
-		}
-	}
+		})
+	})
 }
 
-var PodsThatWas = map[string]string{}
+func (b *Bot) handleCommand(m *tgbotapi.Message) {
+	actor := messageActor(m)
+	args := strings.Fields(m.CommandArguments())
 
-type Status int
+	if !b.allowed(actor, commandRoles[m.Command()]) {
+		return
+	}
 
-// 2) определяем константы с помощью iota
-const (
-	Ok        Status = iota // 0
-	Cancelled               // 1
-	Error                   // 2
-)
+	switch m.Command() {
+	case "start":
+		b.RegisterNamespaces(actor)
+
+	case "subscribe":
+		if len(args) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Использование: /subscribe <namespace> [namespace...]", actionButtons)
+			return
+		}
+		valid := b.ValidateNamespaces(args)
+		var rejected []string
+		for _, ns := range args {
+			if !slices.Contains(valid, ns) {
+				rejected = append(rejected, ns)
+			}
+		}
+		if len(valid) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Namespaces не найдены: "+strings.Join(rejected, " "), actionButtons)
+			return
+		}
+		if err := b.subs.Add(actor.ChatID, valid); err != nil {
+			slog.Error("Не удалось сохранить подписки", "chatID", actor.ChatID, "error", err)
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось подписаться на namespaces", actionButtons)
+			return
+		}
+		text := "Подписка оформлена: " + strings.Join(valid, " ")
+		if len(rejected) > 0 {
+			text += "\nNamespaces не найдены: " + strings.Join(rejected, " ")
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, text, actionButtons)
 
-func (b *Bot) AskPod(updates *tgbotapi.UpdatesChannel, chatId int64, ns string) (string, Status) {
+	case "unsubscribe":
+		if len(args) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Использование: /unsubscribe <namespace> [namespace...]", actionButtons)
+			return
+		}
+		if err := b.subs.Remove(actor.ChatID, args); err != nil {
+			slog.Error("Не удалось удалить подписки", "chatID", actor.ChatID, "error", err)
+			b.MessageWithReplyMarkup(actor.ChatID, "Не удалось отписаться от namespaces", actionButtons)
+			return
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, "Подписка отменена: "+strings.Join(args, " "), actionButtons)
+
+	case "namespaces":
+		namespaces := b.subs.Namespaces(actor.ChatID)
+		if len(namespaces) == 0 {
+			b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан ни на один namespace, используйте /start", actionButtons)
+			return
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, "Отслеживаемые namespaces:\n"+getNamespacesString(namespaces), actionButtons)
+
+	case "whoami":
+		role := domain.RoleNone
+		if user, err := b.users.GetUser(actor.UserID); err == nil && user != nil {
+			role = user.Role
+		}
+		b.MessageWithReplyMarkup(actor.ChatID, fmt.Sprintf("Ваш Telegram ID: %d, роль: %s", actor.UserID, role), actionButtons)
+
+	case "audit":
+		b.showAudit(actor, args)
+
+	case "incidents":
+		b.searchIncidents(actor, args)
+
+	case "stats":
+		b.showStats(actor, args)
+
+	case "export":
+		b.exportIncidents(actor, args)
+
+	case "digest":
+		b.manageDigest(actor, args)
+
+	case "escalation":
+		b.manageEscalation(actor, args)
+
+	case "oncall":
+		b.showOnCall(actor, args)
+
+	case "oncall_set":
+		b.setOnCall(actor, args)
+
+	case "oncall_override":
+		b.overrideOnCall(actor, args)
+
+	case "silence":
+		b.addSilence(actor, args)
+
+	case "silences":
+		b.showSilences(actor)
+
+	case "unsilence":
+		b.removeSilence(actor, args)
+
+	case "users":
+		b.listUsers(actor)
+
+	case "grant":
+		b.grantRole(actor, args)
+
+	case "revoke":
+		b.revokeRole(actor, args)
+
+	default:
+		b.MessageWithReplyMarkup(actor.ChatID, "Неизвестная команда", actionButtons)
+	}
+}
+
+func (b *Bot) AskPod(actor Actor, ns string, next func(pod string)) {
 	askPods := "Какой под (введите число)?\n"
 	podsString, pods, err := getPodsString(b, ns)
 	if err != nil {
-		b.MessageWithReplyMarkup(chatId, err.Error(), actionButtons)
-		return "", Error
-	}
-	podID := WaitNumber(b, updates, chatId, askPods+podsString, int64(len(pods)))
-	if podID == -1 {
-		return "", Cancelled
+		b.MessageWithReplyMarkup(actor.ChatID, err.Error(), actionButtons)
+		return
 	}
-	pod := pods[podID-1]
-
-	return pod, Ok
+	b.askNumber(actor, askPods+podsString, int64(len(pods)), func(podID int64) {
+		next(pods[podID-1])
+	})
 }
 
-func (b *Bot) AskNsAndDeploy(updates *tgbotapi.UpdatesChannel, chatId int64) (string, string, Status) {
-	ns, status := b.AskNamespace(updates, chatId)
-	if status != Ok {
-		return "", "", status
-	}
-	depl, status := b.AskDeploy(updates, chatId, ns)
-	if status != Ok {
-		return ns, "", status
-	}
-	return ns, depl, Ok
+func (b *Bot) AskNsAndDeploy(actor Actor, next func(ns, depl string)) {
+	b.AskNamespace(actor, func(ns string) {
+		b.AskDeploy(actor, ns, func(depl string) {
+			next(ns, depl)
+		})
+	})
 }
 
-func (b *Bot) AskNamespace(updates *tgbotapi.UpdatesChannel, chatId int64) (string, Status) {
-	askNs := "В каком namespace (введите число)?\n" + getNamespacesString(chatId)
-	namespaceId := WaitNumber(b, updates, chatId, askNs, int64(len(ChatIDToNamespaces)))
-	if namespaceId == -1 {
-		return "", Cancelled
+func (b *Bot) AskNamespace(actor Actor, next func(ns string)) {
+	namespaces := b.subs.Namespaces(actor.ChatID)
+	if len(namespaces) == 0 {
+		b.MessageWithReplyMarkup(actor.ChatID, "Чат не подписан ни на один namespace, используйте /start", actionButtons)
+		return
 	}
-	ns := ChatIDToNamespaces[chatId][namespaceId-1]
-	return ns, Ok
+	askNs := "В каком namespace (введите число)?\n" + getNamespacesString(namespaces)
+	b.askNumber(actor, askNs, int64(len(namespaces)), func(namespaceId int64) {
+		next(namespaces[namespaceId-1])
+	})
 }
 
-func (b *Bot) AskDeploy(updates *tgbotapi.UpdatesChannel, chatId int64, ns string) (string, Status) {
+func (b *Bot) AskDeploy(actor Actor, ns string, next func(depl string)) {
 	askDepls := "В каком deployment (введите число)?\n"
 	deplsString, depls, err := getDeploymentsString(b, ns)
 	if err != nil {
-		b.MessageWithReplyMarkup(chatId, err.Error(), actionButtons)
-		return "", Error
+		b.MessageWithReplyMarkup(actor.ChatID, err.Error(), actionButtons)
+		return
 	}
-	deplId := WaitNumber(b, updates, chatId, askDepls+deplsString, int64(len(depls)))
-	if deplId == -1 {
-		return ns, Cancelled
-	}
-	deployment := depls[deplId-1]
-	return deployment, Ok
+	b.askNumber(actor, askDepls+deplsString, int64(len(depls)), func(deplId int64) {
+		next(depls[deplId-1])
+	})
 }
 
 func (b *Bot) MessageWithReplyMarkup(chatID int64, messageText string, replyMarkup interface{}) {
-	MessageWithReplyMarkup(b.bot, chatID, messageText, replyMarkup)
+	MessageWithReplyMarkup(b.sender, chatID, messageText, replyMarkup)
 }
 
-func MessageWithReplyMarkup(api *tgbotapi.BotAPI, chatID int64, messageText string, replyMarkup interface{}) {
+func (b *Bot) HTMLMessage(chatID int64, messageText string) {
 	newMessage := tgbotapi.NewMessage(chatID, messageText)
-	newMessage.ReplyMarkup = replyMarkup
-	_, err := api.Send(newMessage)
-	if err != nil {
-		slog.Error("Can not send reply message", err)
+	newMessage.ParseMode = tgbotapi.ModeHTML
+	newMessage.ReplyMarkup = actionButtons
+	if _, err := b.sender.Send(newMessage); err != nil {
+		slog.Error("Can not send html message", "error", err)
 	}
 }
 
-func (b *Bot) SendAlert(a domain.Alert) {
-	var msg tgbotapi.MessageConfig
-
-	ns, err := b.k8sController.GetNamespaceFromPod(context.Background(), a.Labels.Pod)
-	if err != nil {
-		slog.Error("getting namespace from pod name", err)
-		ns = PodsThatWas[a.Labels.Pod]
-	} else {
-		PodsThatWas[a.Labels.Pod] = ns
-	}
-
-	err = b.repo.WriteAlert(a, ns)
+func MessageWithReplyMarkup(sender *Sender, chatID int64, messageText string, replyMarkup interface{}) {
+	newMessage := tgbotapi.NewMessage(chatID, messageText)
+	newMessage.ReplyMarkup = replyMarkup
+	_, err := sender.Send(newMessage)
 	if err != nil {
-		slog.Error("Не удалось записать алерт", err)
-	}
-
-	for _, chatID := range NamespacesToChatIDs[ns] {
-		msg = tgbotapi.NewMessage(chatID, a.String())
-		b.bot.Send(msg)
+		slog.Error("Can not send reply message", "error", err)
 	}
 }
 
@@ -580,33 +697,33 @@ func GetPhotoMessageForGrafana(chatId int64) *tgbotapi.PhotoConfig {
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	if err != nil {
-		slog.Error("Не удалось получить kafka png", err)
+		slog.Error("Не удалось получить kafka png", "error", err)
 		return nil
 	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		slog.Error("Не удалось получить kafka png", err)
+		slog.Error("Не удалось получить kafka png", "error", err)
 		return nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		slog.Error("Failed to download file: %s", resp.Status)
+		slog.Error("Failed to download file", "status", resp.Status)
 	}
 
 	buf := new(bytes.Buffer)
 
 	_, err = io.Copy(buf, resp.Body)
 	if err != nil {
-		slog.Error("Failed to copy bytes png", err)
+		slog.Error("Failed to copy bytes png", "error", err)
 		return nil
 	}
 
 	img, err := png.Decode(buf)
 	if err != nil {
-		slog.Error("Failed to decode png", err)
+		slog.Error("Failed to decode png", "error", err)
 		return nil
 	}
 
@@ -647,7 +764,7 @@ func cropTopPixelsPNG(img image.Image, cropY int) ([]byte, error) {
 	var buf bytes.Buffer
 	err := png.Encode(&buf, dst)
 	if err != nil {
-		slog.Error("Failed to encode PNG:", err)
+		slog.Error("Failed to encode PNG", "error", err)
 		return nil, err
 	}
 
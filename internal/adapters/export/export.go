@@ -0,0 +1,134 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"hack-a-tone/internal/core/domain"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Record инцидент в формате выгрузки и HTTP API
+type Record struct {
+	ID              int64             `json:"id"`
+	Namespace       string            `json:"namespace"`
+	Deployment      string            `json:"deployment,omitempty"`
+	Status          string            `json:"status"`
+	Alertname       string            `json:"alertname"`
+	Pod             string            `json:"pod,omitempty"`
+	Summary         string            `json:"summary,omitempty"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
+	Fingerprint     string            `json:"fingerprint"`
+	StartsAt        *time.Time        `json:"startsAt,omitempty"`
+	EndsAt          *time.Time        `json:"endsAt,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	DurationSeconds int64             `json:"durationSeconds"`
+	AckedBy         int64             `json:"ackedBy,omitempty"`
+	AckedByName     string            `json:"ackedByName,omitempty"`
+	AckedAt         *time.Time        `json:"ackedAt,omitempty"`
+	EscalationLevel int               `json:"escalationLevel"`
+	Suppressed      bool              `json:"suppressed"`
+	GeneratorURL    string            `json:"generatorURL,omitempty"`
+	DashboardURL    string            `json:"dashboardURL,omitempty"`
+	PanelURL        string            `json:"panelURL,omitempty"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func NewRecord(a domain.Alert) Record {
+	return Record{
+		ID:              a.ID,
+		Namespace:       a.Namespace,
+		Deployment:      a.Deployment,
+		Status:          a.Status,
+		Alertname:       a.Labels.Alertname,
+		Pod:             a.Labels.Pod,
+		Summary:         a.Annotations.Summary,
+		Labels:          a.Labels.Map(),
+		Annotations:     a.Annotations.Map(),
+		Fingerprint:     a.Fingerprint,
+		StartsAt:        timePtr(a.StartsAt),
+		EndsAt:          timePtr(a.EndsAt),
+		CreatedAt:       a.CreatedAt,
+		DurationSeconds: int64(a.Duration() / time.Second),
+		AckedBy:         a.AckedBy,
+		AckedByName:     a.AckedByName,
+		AckedAt:         timePtr(a.AckedAt),
+		EscalationLevel: a.EscalationLevel,
+		Suppressed:      a.Suppressed,
+		GeneratorURL:    a.GeneratorURL,
+		DashboardURL:    a.DashboardURL,
+		PanelURL:        a.PanelURL,
+	}
+}
+
+// WriteJSON пишет инциденты JSON-массивом
+func WriteJSON(w io.Writer, alerts []domain.Alert) error {
+	records := make([]Record, len(alerts))
+	for i, a := range alerts {
+		records[i] = NewRecord(a)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+var csvHeader = []string{
+	"id", "namespace", "deployment", "status", "alertname", "pod", "summary", "fingerprint",
+	"starts_at", "ends_at", "duration_seconds", "acked_by", "acked_at", "escalation_level", "suppressed", "generator_url",
+}
+
+// WriteCSV пишет инциденты таблицей CSV с заголовком. Время в RFC3339, UTC
+func WriteCSV(w io.Writer, alerts []domain.Alert) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, a := range alerts {
+		err := cw.Write([]string{
+			strconv.FormatInt(a.ID, 10),
+			a.Namespace,
+			a.Deployment,
+			a.Status,
+			a.Labels.Alertname,
+			a.Labels.Pod,
+			a.Annotations.Summary,
+			a.Fingerprint,
+			formatTime(a.StartsAt),
+			formatTime(a.EndsAt),
+			strconv.FormatInt(int64(a.Duration()/time.Second), 10),
+			a.AckedByName,
+			formatTime(a.AckedAt),
+			strconv.Itoa(a.EscalationLevel),
+			strconv.FormatBool(a.Suppressed),
+			a.GeneratorURL,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
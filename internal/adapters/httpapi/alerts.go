@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"hack-a-tone/internal/adapters/export"
+	"hack-a-tone/internal/core/domain"
+	"hack-a-tone/internal/core/port"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AlertsHandler отдает инциденты по фильтру: GET /api/alerts?namespace=prod&status=firing&since=24h&limit=50
+type AlertsHandler struct {
+	repo port.AlertRepo
+}
+
+func NewAlertsHandler(repo port.AlertRepo) *AlertsHandler {
+	return &AlertsHandler{repo: repo}
+}
+
+type alertsResponse struct {
+	Alerts []export.Record `json:"alerts"`
+	// NextOffset offset следующей страницы, если она может быть
+	NextOffset *int `json:"nextOffset,omitempty"`
+}
+
+func (h *AlertsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	filter, err := domain.ParseAlertFilter(r.URL.Query(), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	alerts, err := h.repo.FindAlerts(filter)
+	if err != nil {
+		slog.Error("Не удалось получить алерты для API", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to query alerts")
+		return
+	}
+
+	resp := alertsResponse{Alerts: make([]export.Record, 0, len(alerts))}
+	for _, a := range alerts {
+		resp.Alerts = append(resp.Alerts, export.NewRecord(a))
+	}
+	if len(alerts) == filter.Limit {
+		next := filter.Offset + filter.Limit
+		resp.NextOffset = &next
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Не удалось записать ответ API", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServerConfig настройки HTTP-сервера для вебхука и API
+type ServerConfig struct {
+	Addr         string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxBodyBytes ограничивает размер тела запроса, 0 — без ограничения
+	MaxBodyBytes int64
+	// ShutdownTimeout сколько ждать завершения активных запросов при остановке
+	ShutdownTimeout time.Duration
+}
+
+type Server struct {
+	srv             *http.Server
+	shutdownTimeout time.Duration
+}
+
+func NewServer(cfg ServerConfig, handler http.Handler) *Server {
+	if cfg.MaxBodyBytes > 0 {
+		handler = limitBody(handler, cfg.MaxBodyBytes)
+	}
+
+	return &Server{
+		srv: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// Run слушает адрес до отмены ctx, после чего дожидается завершения активных запросов
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.srv.Addr, err)
+	}
+	slog.Info("HTTP-сервер запущен", "addr", ln.Addr().String())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.srv.Serve(ln)
+	}()
+
+	select {
+	case err = <-errCh:
+		return fmt.Errorf("http server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err = s.srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown http server: %w", err)
+	}
+	if err = <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http server stopped: %w", err)
+	}
+
+	slog.Info("HTTP-сервер остановлен")
+	return nil
+}
+
+func limitBody(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"hack-a-tone/internal/core/port"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultStatsPeriod = 7 * 24 * time.Hour
+	statsTopAlerts     = 5
+)
+
+// StatsHandler отдает статистику инцидентов: GET /api/stats?namespace=prod&since=168h&by=deployment
+type StatsHandler struct {
+	repo port.AlertRepo
+}
+
+func NewStatsHandler(repo port.AlertRepo) *StatsHandler {
+	return &StatsHandler{repo: repo}
+}
+
+type statsView struct {
+	domain.IncidentStats
+	MTTASeconds int64 `json:"mttaSeconds"`
+	MTTRSeconds int64 `json:"mttrSeconds"`
+}
+
+type statsResponse struct {
+	From  time.Time   `json:"from"`
+	To    time.Time   `json:"to"`
+	Stats []statsView `json:"stats"`
+}
+
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	filter, err := domain.ParseAlertFilter(query, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.From.IsZero() {
+		filter.From = now.Add(-defaultStatsPeriod)
+	}
+	if filter.To.IsZero() {
+		filter.To = now
+	}
+	filter.Limit = domain.NoAlertLimit
+
+	groupBy := query.Get("by")
+	if groupBy == "" {
+		groupBy = domain.StatsByNamespace
+	}
+	if groupBy != domain.StatsByNamespace && groupBy != domain.StatsByDeployment {
+		writeError(w, http.StatusBadRequest, "by must be namespace or deployment")
+		return
+	}
+
+	alerts, err := h.repo.FindAlerts(filter)
+	if err != nil {
+		slog.Error("Не удалось получить алерты для статистики", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to query alerts")
+		return
+	}
+
+	resp := statsResponse{From: filter.From, To: filter.To, Stats: []statsView{}}
+	for _, s := range domain.CalculateStats(alerts, groupBy, statsTopAlerts) {
+		resp.Stats = append(resp.Stats, statsView{
+			IncidentStats: s,
+			MTTASeconds:   int64(s.MTTA / time.Second),
+			MTTRSeconds:   int64(s.MTTR / time.Second),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
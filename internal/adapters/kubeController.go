@@ -101,6 +101,16 @@ func (ctrl *KubeRuntimeController) GetAllPods(ctx context.Context, nameSpace str
 	return response, err
 }
 
+func (ctrl *KubeRuntimeController) GetPod(ctx context.Context, nameSpace, podName string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	err := ctrl.client.Get(ctx, types.NamespacedName{Name: podName, Namespace: nameSpace}, pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", nameSpace, podName, err)
+	}
+
+	return pod, nil
+}
+
 func (ctrl *KubeRuntimeController) GetDeployments(ctx context.Context, nameSpace string) (*v1.DeploymentList, error) {
 	response := &v1.DeploymentList{}
 
@@ -109,10 +119,10 @@ func (ctrl *KubeRuntimeController) GetDeployments(ctx context.Context, nameSpace
 		opt.Namespace = nameSpace
 	}
 
-	slog.Info("Asking k8s client to list deployments for namespace -", nameSpace)
+	slog.Info("Asking k8s client to list deployments", "namespace", nameSpace)
 	err := ctrl.client.List(ctx, response, opt)
 	if err != nil {
-		slog.Error("Get deployment list:", err)
+		slog.Error("Get deployment list", "error", err)
 		return nil, fmt.Errorf("failed to get deployments: %w", err)
 	}
 
@@ -327,7 +337,7 @@ func getDeploymentStatus(deploy v1.Deployment) string {
 	for _, cond := range deploy.Status.Conditions {
 		if cond.Type == v1.DeploymentAvailable {
 			if cond.Status == corev1.ConditionTrue {
-				return "Available"
+				return domain.DeploymentAvailable
 			} else if cond.Reason != "" {
 				return cond.Reason // например: "MinimumReplicasNotAvailable"
 			}
@@ -357,7 +367,7 @@ func (ctrl *KubeRuntimeController) StatusAll(ctx context.Context) ([]domain.Depl
 	for _, deploy := range deployments.Items {
 		selector := client.MatchingLabels(deploy.Spec.Selector.MatchLabels)
 		var podList corev1.PodList
-		if err := ctrl.client.List(ctx, &podList, selector); err != nil {
+		if err := ctrl.client.List(ctx, &podList, selector, client.InNamespace(deploy.Namespace)); err != nil {
 			return nil, fmt.Errorf("failed to list pods for deployment %s: %w", deploy.Name, err)
 		}
 		pods := make(map[string]domain.PodStatus)
@@ -390,9 +400,10 @@ func (ctrl *KubeRuntimeController) StatusAll(ctx context.Context) ([]domain.Depl
 		}
 
 		result = append(result, domain.DeployStatus{
-			Name:   deploy.Name,
-			Status: deployStatus,
-			Pods:   pods,
+			Name:      deploy.Name,
+			Namespace: deploy.Namespace,
+			Status:    deployStatus,
+			Pods:      pods,
 		})
 	}
 
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"context"
+	"expvar"
+	"hack-a-tone/internal/core/domain"
+	"hack-a-tone/internal/core/port"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var stats = expvar.NewMap("alert_queue")
+
+// Processor обрабатывает алерты из очереди в два шага, чтобы повтор после ошибки отправки
+// не записывал алерт второй раз
+type Processor interface {
+	// IngestAlert записывает алерт в хранилище и возвращает его ID. 0 — уведомления не нужны
+	IngestAlert(a domain.Alert) (int64, error)
+	// NotifyAlert рассылает уведомления об алерте. Повторный вызов не дублирует отправленные сообщения
+	NotifyAlert(alertID int64) error
+}
+
+// pruneInterval как часто удаляются старые необработанные алерты
+const pruneInterval = time.Hour
+
+type Config struct {
+	Workers int
+	// BatchSize сколько алертов захватывается за раз. Пачка должна успеть обработаться за Lease
+	// с учетом лимитов отправки в Telegram
+	BatchSize    int
+	PollInterval time.Duration
+	// Lease на сколько алерт блокируется при захвате. Должен быть больше времени обработки пачки
+	Lease       time.Duration
+	MaxAttempts int
+	// Задержка повтора растет от BaseDelay вдвое с каждой попыткой, но не больше MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailedRetention сколько хранить алерты, которые не удалось обработать. 0 — хранить всегда
+	FailedRetention time.Duration
+}
+
+// Queue принимает алерты из вебхука в хранилище и обрабатывает их в фоне
+type Queue struct {
+	repo port.QueueRepo
+	proc Processor
+	cfg  Config
+	wake chan struct{}
+}
+
+func NewQueue(repo port.QueueRepo, proc Processor, cfg Config) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Queue{repo: repo, proc: proc, cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// AcceptAlerts сохраняет алерты в очередь. После успешного ответа алерты не потеряются
+func (q *Queue) AcceptAlerts(alerts domain.Alerts) error {
+	if err := q.repo.EnqueueAlerts(alerts, time.Now()); err != nil {
+		return err
+	}
+	stats.Add("enqueued", int64(len(alerts)))
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run обрабатывает очередь до отмены ctx. Начатая пачка дорабатывается до конца
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var pruned time.Time
+	for {
+		for ctx.Err() == nil && q.processBatch() > 0 {
+		}
+		if now := time.Now(); now.Sub(pruned) >= pruneInterval {
+			q.pruneFailed(now)
+			pruned = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// processBatch забирает пачку алертов и раскладывает ее по воркерам. Алерты с одним fingerprint
+// попадают к одному воркеру и обрабатываются в порядке поступления
+func (q *Queue) processBatch() int {
+	items, err := q.repo.ClaimQueuedAlerts(time.Now(), q.cfg.Lease, q.cfg.BatchSize)
+	if err != nil {
+		slog.Error("Не удалось получить алерты из очереди", "error", err)
+		return 0
+	}
+	if len(items) == 0 {
+		return 0
+	}
+
+	parts := make([][]domain.QueuedAlert, q.cfg.Workers)
+	for _, item := range items {
+		n := partition(item.Alert, q.cfg.Workers)
+		parts[n] = append(parts[n], item)
+	}
+
+	var wg sync.WaitGroup
+	for _, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(part []domain.QueuedAlert) {
+			defer wg.Done()
+			for _, item := range part {
+				q.process(item)
+			}
+		}(part)
+	}
+	wg.Wait()
+
+	return len(items)
+}
+
+func (q *Queue) process(item domain.QueuedAlert) {
+	if item.AlertID == 0 && item.Attempts > 1 {
+		// Прошлая попытка могла записать алерт и упасть до SetQueuedAlertID. Повторная запись
+		// приняла бы его за дубликат, и уведомление потерялось бы
+		alertID, err := q.repo.FindQueuedAlert(item.ID)
+		if err != nil {
+			q.retry(item, err)
+			return
+		}
+		item.AlertID = alertID
+	}
+	if item.AlertID == 0 {
+		item.Alert.QueueID = item.ID
+		alertID, err := q.proc.IngestAlert(item.Alert)
+		if err != nil {
+			q.retry(item, err)
+			return
+		}
+		if alertID == 0 {
+			q.complete(item)
+			return
+		}
+		if err = q.repo.SetQueuedAlertID(item.ID, alertID); err != nil {
+			slog.Error("Не удалось сохранить ID алерта в очереди", "id", item.ID, "alertID", alertID, "error", err)
+		}
+		item.AlertID = alertID
+	}
+
+	if err := q.proc.NotifyAlert(item.AlertID); err != nil {
+		q.retry(item, err)
+		return
+	}
+	q.complete(item)
+}
+
+func (q *Queue) complete(item domain.QueuedAlert) {
+	stats.Add("processed", 1)
+	if err := q.repo.CompleteQueuedAlert(item.ID); err != nil {
+		slog.Error("Не удалось удалить алерт из очереди", "id", item.ID, "error", err)
+	}
+}
+
+func (q *Queue) retry(item domain.QueuedAlert, cause error) {
+	if item.Attempts >= q.cfg.MaxAttempts {
+		stats.Add("failed", 1)
+		slog.Error("Алерт не обработан, попытки исчерпаны", "id", item.ID, "attempts", item.Attempts,
+			"alertname", item.Alert.Labels.Alertname, "error", cause)
+		if err := q.repo.FailQueuedAlert(item.ID, cause.Error()); err != nil {
+			slog.Error("Не удалось отметить алерт в очереди", "id", item.ID, "error", err)
+		}
+		return
+	}
+
+	delay := q.backoff(item.Attempts)
+	stats.Add("retried", 1)
+	slog.Warn("Ошибка обработки алерта, повтор позже", "id", item.ID, "attempt", item.Attempts, "delay", delay, "error", cause)
+	if err := q.repo.RetryQueuedAlert(item.ID, time.Now().Add(delay), cause.Error()); err != nil {
+		slog.Error("Не удалось отложить алерт в очереди", "id", item.ID, "error", err)
+	}
+}
+
+// pruneFailed удаляет необработанные алерты старше FailedRetention, чтобы очередь не росла бесконечно
+func (q *Queue) pruneFailed(now time.Time) {
+	if q.cfg.FailedRetention <= 0 {
+		return
+	}
+	n, err := q.repo.DeleteFailedQueuedAlerts(now.Add(-q.cfg.FailedRetention))
+	if err != nil {
+		slog.Error("Не удалось удалить необработанные алерты из очереди", "error", err)
+		return
+	}
+	if n > 0 {
+		stats.Add("pruned", n)
+		slog.Info("Необработанные алерты удалены из очереди", "count", n)
+	}
+}
+
+// backoff возвращает задержку перед следующей попыткой после attempt неудачных
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.cfg.BaseDelay
+	for i := 1; i < attempt && delay < q.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, q.cfg.MaxDelay)
+}
+
+func partition(a domain.Alert, n int) int {
+	fingerprint := a.Fingerprint
+	if fingerprint == "" {
+		fingerprint = a.Labels.Fingerprint()
+	}
+	h := fnv.New32a()
+	h.Write([]byte(fingerprint))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"errors"
+	"hack-a-tone/internal/adapters/storage"
+	"hack-a-tone/internal/core/domain"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	q := NewQueue(nil, nil, Config{BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 8 * time.Second},
+		{attempt: 5, want: 10 * time.Second},
+		{attempt: 50, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// fakeRepo запоминает, чем закончилась обработка каждого алерта
+type fakeRepo struct {
+	items     []domain.QueuedAlert
+	alertIDs  map[int64]int64
+	written   map[int64]int64
+	completed []int64
+	retried   map[int64]time.Time
+	failed    map[int64]string
+
+	prunedBefore []time.Time
+}
+
+func newFakeRepo(items ...domain.QueuedAlert) *fakeRepo {
+	return &fakeRepo{
+		items: items, alertIDs: map[int64]int64{}, written: map[int64]int64{},
+		retried: map[int64]time.Time{}, failed: map[int64]string{},
+	}
+}
+
+func (r *fakeRepo) EnqueueAlerts(alerts []domain.Alert, at time.Time) error { return nil }
+
+func (r *fakeRepo) ClaimQueuedAlerts(now time.Time, lease time.Duration, limit int) ([]domain.QueuedAlert, error) {
+	items := r.items
+	r.items = nil
+	return items, nil
+}
+
+func (r *fakeRepo) SetQueuedAlertID(id, alertID int64) error {
+	r.alertIDs[id] = alertID
+	return nil
+}
+
+func (r *fakeRepo) FindQueuedAlert(id int64) (int64, error) {
+	return r.written[id], nil
+}
+
+func (r *fakeRepo) CompleteQueuedAlert(id int64) error {
+	r.completed = append(r.completed, id)
+	return nil
+}
+
+func (r *fakeRepo) RetryQueuedAlert(id int64, next time.Time, lastErr string) error {
+	r.retried[id] = next
+	return nil
+}
+
+func (r *fakeRepo) FailQueuedAlert(id int64, lastErr string) error {
+	r.failed[id] = lastErr
+	return nil
+}
+
+func (r *fakeRepo) DeleteFailedQueuedAlerts(before time.Time) (int64, error) {
+	r.prunedBefore = append(r.prunedBefore, before)
+	return 1, nil
+}
+
+func TestPruneFailed(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		retention time.Duration
+		want      []time.Time
+	}{
+		{name: "disabled", retention: 0},
+		{name: "older than retention", retention: 24 * time.Hour, want: []time.Time{now.Add(-24 * time.Hour)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			NewQueue(repo, nil, Config{FailedRetention: tt.retention}).pruneFailed(now)
+			if len(repo.prunedBefore) != len(tt.want) || (len(tt.want) > 0 && !repo.prunedBefore[0].Equal(tt.want[0])) {
+				t.Errorf("pruned before %v, want %v", repo.prunedBefore, tt.want)
+			}
+		})
+	}
+}
+
+// fakeProcessor ведет журнал вызовов и возвращает заданные ошибки
+type fakeProcessor struct {
+	mu        sync.Mutex
+	calls     []string
+	notified  []int64
+	ingestErr func(a domain.Alert) error
+	notifyErr error
+	nextID    int64
+}
+
+func (p *fakeProcessor) IngestAlert(a domain.Alert) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, "ingest "+a.Labels.Alertname+" "+a.Status)
+	if p.ingestErr != nil {
+		if err := p.ingestErr(a); err != nil {
+			return 0, err
+		}
+	}
+	p.nextID++
+	return p.nextID, nil
+}
+
+func (p *fakeProcessor) NotifyAlert(alertID int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notified = append(p.notified, alertID)
+	return p.notifyErr
+}
+
+func queuedAlert(id int64, attempts int, alertID int64) domain.QueuedAlert {
+	a := domain.Alert{Status: domain.StatusFiring, Fingerprint: "fp"}
+	a.Labels.Set("alertname", "A")
+	return domain.QueuedAlert{ID: id, Alert: a, Attempts: attempts, AlertID: alertID}
+}
+
+func TestProcessRetry(t *testing.T) {
+	cfg := Config{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+
+	tests := []struct {
+		name         string
+		item         domain.QueuedAlert
+		written      int64
+		ingestErr    error
+		notifyErr    error
+		wantComplete bool
+		wantDelay    time.Duration
+		wantFailed   bool
+		wantAlertID  int64
+		wantIngest   bool
+		wantNotified int64
+	}{
+		{name: "processed", item: queuedAlert(1, 1, 0), wantComplete: true, wantAlertID: 1, wantIngest: true, wantNotified: 1},
+		{
+			name: "ingest error retried", item: queuedAlert(1, 1, 0), ingestErr: errors.New("db"),
+			wantDelay: time.Minute, wantIngest: true,
+		},
+		{
+			name: "notify error retried with saved alert id", item: queuedAlert(1, 2, 0),
+			notifyErr: errors.New("telegram"), wantDelay: 2 * time.Minute, wantAlertID: 1, wantIngest: true, wantNotified: 1,
+		},
+		{name: "already ingested alert is only notified", item: queuedAlert(1, 2, 7), wantComplete: true, wantNotified: 7},
+		{
+			name: "alert written before crash is notified", item: queuedAlert(1, 2, 0), written: 5,
+			wantComplete: true, wantNotified: 5,
+		},
+		{
+			name: "attempts exhausted", item: queuedAlert(1, 3, 7), notifyErr: errors.New("telegram"),
+			wantFailed: true, wantNotified: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo(tt.item)
+			if tt.written != 0 {
+				repo.written[tt.item.ID] = tt.written
+			}
+			proc := &fakeProcessor{
+				ingestErr: func(domain.Alert) error { return tt.ingestErr },
+				notifyErr: tt.notifyErr,
+			}
+			q := NewQueue(repo, proc, cfg)
+
+			start := time.Now()
+			if n := q.processBatch(); n != 1 {
+				t.Fatalf("processBatch() = %d, want 1", n)
+			}
+
+			if got := len(repo.completed) == 1; got != tt.wantComplete {
+				t.Errorf("completed = %v, want %v", repo.completed, tt.wantComplete)
+			}
+			if _, got := repo.failed[tt.item.ID]; got != tt.wantFailed {
+				t.Errorf("failed = %v, want %v", repo.failed, tt.wantFailed)
+			}
+			next, retried := repo.retried[tt.item.ID]
+			if retried != (tt.wantDelay > 0) {
+				t.Fatalf("retried = %v, want delay %s", repo.retried, tt.wantDelay)
+			}
+			if retried && (next.Before(start.Add(tt.wantDelay)) || next.After(time.Now().Add(tt.wantDelay))) {
+				t.Errorf("next attempt in %s, want %s", next.Sub(start), tt.wantDelay)
+			}
+			if got := repo.alertIDs[tt.item.ID]; got != tt.wantAlertID {
+				t.Errorf("saved alert id = %d, want %d", got, tt.wantAlertID)
+			}
+			if got := len(proc.calls) > 0; got != tt.wantIngest {
+				t.Errorf("ingest calls = %q, want ingest %v", proc.calls, tt.wantIngest)
+			}
+			if tt.wantNotified != 0 && (len(proc.notified) != 1 || proc.notified[0] != tt.wantNotified) {
+				t.Errorf("notified = %v, want %d", proc.notified, tt.wantNotified)
+			}
+		})
+	}
+}
+
+// Повтор firing не должен обогнать следующий за ним resolved того же алерта
+func TestQueueOrderPerFingerprint(t *testing.T) {
+	repo, err := storage.NewSQLRepo(storage.Config{
+		DSN:         filepath.Join(t.TempDir(), "alerts.db"),
+		WAL:         true,
+		BusyTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	alert := func(name, status string) domain.Alert {
+		a := domain.Alert{Status: status, Fingerprint: "fp-" + name}
+		a.Labels.Set("alertname", name)
+		return a
+	}
+
+	failOnce := true
+	proc := &fakeProcessor{ingestErr: func(a domain.Alert) error {
+		if a.Labels.Alertname == "A" && failOnce {
+			failOnce = false
+			return errors.New("db is down")
+		}
+		return nil
+	}}
+	q := NewQueue(repo, proc, Config{
+		Workers: 2, Lease: time.Minute, MaxAttempts: 5, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second,
+	})
+
+	err = q.AcceptAlerts(domain.Alerts{
+		alert("A", domain.StatusFiring), alert("A", domain.StatusResolved), alert("B", domain.StatusFiring),
+	})
+	if err != nil {
+		t.Fatalf("AcceptAlerts: %v", err)
+	}
+
+	steps := []struct {
+		wait  time.Duration
+		calls []string
+	}{
+		// resolved ждет, пока не обработан firing того же алерта
+		{calls: []string{"ingest A firing", "ingest B firing"}},
+		{calls: nil},
+		{wait: 100 * time.Millisecond, calls: []string{"ingest A firing"}},
+		{calls: []string{"ingest A resolved"}},
+		{calls: nil},
+	}
+	for i, step := range steps {
+		time.Sleep(step.wait)
+		proc.calls = nil
+		q.processBatch()
+		if !sameCalls(proc.calls, step.calls) {
+			t.Fatalf("step %d: calls = %q, want %q", i, proc.calls, step.calls)
+		}
+	}
+}
+
+// sameCalls сравнивает вызовы без учета порядка: воркеры обрабатывают разные алерты параллельно
+func sameCalls(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	left := map[string]int{}
+	for _, c := range got {
+		left[c]++
+	}
+	for _, c := range want {
+		if left[c] == 0 {
+			return false
+		}
+		left[c]--
+	}
+	return true
+}
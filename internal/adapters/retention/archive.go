@@ -0,0 +1,91 @@
+package retention
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// GzipArchiver пишет удаляемые алерты в файлы alerts-<время>-<id>.json.gz в каталоге Dir,
+// по одному JSON-объекту на строку
+type GzipArchiver struct {
+	Dir string
+}
+
+func NewGzipArchiver(dir string) (*GzipArchiver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive dir: %w", err)
+	}
+	return &GzipArchiver{Dir: dir}, nil
+}
+
+// archivedAlert запись архива. Служебные поля алерта не сериализуются в JSON, поэтому перечислены отдельно
+type archivedAlert struct {
+	ID              int64           `json:"id"`
+	Namespace       string          `json:"namespace"`
+	Deployment      string          `json:"deployment,omitempty"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	AckedBy         int64           `json:"ackedBy,omitempty"`
+	AckedByName     string          `json:"ackedByName,omitempty"`
+	AckedAt         *time.Time      `json:"ackedAt,omitempty"`
+	EscalationLevel int             `json:"escalationLevel,omitempty"`
+	Suppressed      bool            `json:"suppressed,omitempty"`
+	Alert           domain.Alert    `json:"alert"`
+	Raw             json.RawMessage `json:"raw,omitempty"`
+}
+
+func (g *GzipArchiver) Archive(alerts []domain.Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf("alerts-%s-%d.json.gz", time.Now().UTC().Format("20060102-150405"), alerts[0].ID)
+	path := filepath.Join(g.Dir, name)
+
+	// Пишем во временный файл, чтобы в каталоге не оставались обрезанные архивы
+	tmp, err := os.CreateTemp(g.Dir, name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(zw)
+	for _, a := range alerts {
+		rec := archivedAlert{
+			ID:              a.ID,
+			Namespace:       a.Namespace,
+			Deployment:      a.Deployment,
+			CreatedAt:       a.CreatedAt,
+			AckedBy:         a.AckedBy,
+			AckedByName:     a.AckedByName,
+			EscalationLevel: a.EscalationLevel,
+			Suppressed:      a.Suppressed,
+			Alert:           a,
+		}
+		if a.IsAcked() {
+			rec.AckedAt = &a.AckedAt
+		}
+		if json.Valid(a.Raw) {
+			rec.Raw = a.Raw
+		}
+		if err = enc.Encode(rec); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
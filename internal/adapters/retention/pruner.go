@@ -0,0 +1,86 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"hack-a-tone/internal/core/port"
+	"log/slog"
+	"time"
+)
+
+const batchSize = 500
+
+// Archiver сохраняет алерты перед удалением из базы
+type Archiver interface {
+	Archive(alerts []domain.Alert) error
+}
+
+// Pruner периодически удаляет из базы алерты по политике хранения
+type Pruner struct {
+	repo     port.RetentionRepo
+	policy   domain.RetentionPolicy
+	archiver Archiver
+}
+
+// NewPruner создает задачу очистки. archiver может быть nil, тогда алерты удаляются без архива
+func NewPruner(repo port.RetentionRepo, policy domain.RetentionPolicy, archiver Archiver) *Pruner {
+	return &Pruner{repo: repo, policy: policy, archiver: archiver}
+}
+
+func (p *Pruner) Run(ctx context.Context, interval time.Duration) {
+	if !p.policy.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Prune(time.Now()); err != nil {
+			slog.Error("Не удалось очистить старые алерты", "error", err)
+		} else if n > 0 {
+			slog.Info("Старые алерты удалены", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune удаляет пачками все алерты, вышедшие за политику, и возвращает их количество.
+// Если архивирование не удалось, пачка остается в базе
+func (p *Pruner) Prune(now time.Time) (int, error) {
+	total := 0
+	for {
+		alerts, err := p.repo.GetExpiredAlerts(p.policy, now, batchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(alerts) == 0 {
+			return total, nil
+		}
+
+		if p.archiver != nil {
+			if err = p.archiver.Archive(alerts); err != nil {
+				return total, fmt.Errorf("failed to archive alerts: %w", err)
+			}
+		}
+
+		ids := make([]int64, len(alerts))
+		for i, a := range alerts {
+			ids[i] = a.ID
+		}
+		if err = p.repo.DeleteAlerts(ids); err != nil {
+			return total, err
+		}
+
+		total += len(alerts)
+		if len(alerts) < batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+)
+
+func (r *SQLRepo) WriteAudit(entry domain.AuditEntry) error {
+	_, err := r.exec(`
+        INSERT INTO audit_log (user_id, user_name, chat_id, action, namespace, deployment, params, result, error)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.UserID, entry.UserName, entry.ChatID, entry.Action, entry.Namespace,
+		entry.Deployment, entry.Params, entry.Result, entry.Error,
+	)
+	if err != nil {
+		slog.Error("Не удалось записать действие в журнал", "action", entry.Action, "error", err)
+	}
+
+	return err
+}
+
+func (r *SQLRepo) GetLastNAudit(n int, namespace string) ([]domain.AuditEntry, error) {
+	rows, err := r.query(`
+        SELECT id, user_id, user_name, chat_id, action, namespace, deployment, params, result, error, created_at
+        FROM audit_log
+        WHERE namespace = ?
+        ORDER BY id DESC
+        LIMIT ?
+    `, namespace, n)
+	if err != nil {
+		slog.Error("Ошибка выборки журнала действий", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditEntry
+	for rows.Next() {
+		var e domain.AuditEntry
+		err = rows.Scan(&e.ID, &e.UserID, &e.UserName, &e.ChatID, &e.Action, &e.Namespace,
+			&e.Deployment, &e.Params, &e.Result, &e.Error, &e.CreatedAt)
+		if err != nil {
+			slog.Error("Ошибка чтения записи журнала", "error", err)
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
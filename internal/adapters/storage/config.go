@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+
+	defaultSQLitePath = "./alerts.db"
+)
+
+// Config параметры подключения к базе. Нулевые значения означают настройки драйвера по умолчанию
+type Config struct {
+	// Driver sqlite (по умолчанию) или postgres
+	Driver string
+	// DSN путь к файлу SQLite или строка подключения Postgres
+	DSN string
+
+	// WAL включает журнал WAL в SQLite, чтобы чтение не блокировалось записью
+	WAL bool
+	// BusyTimeout сколько SQLite ждет снятия блокировки, прежде чем вернуть SQLITE_BUSY
+	BusyTimeout time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func (c Config) dialect() (dialect, error) {
+	switch c.Driver {
+	case "", DriverSQLite:
+		return sqliteDialect, nil
+	case DriverPostgres:
+		return postgresDialect, nil
+	}
+	return dialect{}, fmt.Errorf("unknown database driver %q", c.Driver)
+}
+
+// dsn дополняет строку подключения SQLite параметрами go-sqlite3
+func (c Config) dsn(d dialect) (string, error) {
+	if d.name != DriverSQLite {
+		if c.DSN == "" {
+			return "", fmt.Errorf("DSN is required for %s", d.name)
+		}
+		return c.DSN, nil
+	}
+
+	dsn := c.DSN
+	if dsn == "" {
+		dsn = defaultSQLitePath
+	}
+
+	var params []string
+	if c.WAL {
+		params = append(params, "_journal_mode=WAL")
+	}
+	if c.BusyTimeout > 0 {
+		params = append(params, "_busy_timeout="+strconv.FormatInt(c.BusyTimeout.Milliseconds(), 10))
+	}
+	if len(params) == 0 {
+		return dsn, nil
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&"), nil
+}
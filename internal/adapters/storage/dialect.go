@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialect описывает различия между SQLite и Postgres, которые видны репозиторию.
+// Запросы пишутся с плейсхолдерами ?, для Postgres они переписываются в $1, $2...
+type dialect struct {
+	name       string
+	driver     string
+	numbered   bool
+	migrations []migration
+	// lockSQL и unlockSQL защищают миграции от одновременного запуска
+	lockSQL   string
+	unlockSQL string
+}
+
+var (
+	sqliteDialect = dialect{name: "sqlite", driver: "sqlite3", migrations: sqliteMigrations}
+
+	postgresDialect = dialect{
+		name:       "postgres",
+		driver:     "pgx",
+		numbered:   true,
+		migrations: postgresMigrations,
+		lockSQL:    "SELECT pg_advisory_lock(7340215)",
+		unlockSQL:  "SELECT pg_advisory_unlock(7340215)",
+	}
+)
+
+// rebind заменяет плейсхолдеры ? на нумерованные, если этого требует диалект
+func (d dialect) rebind(query string) string {
+	if !d.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			sb.WriteByte(query[i])
+			continue
+		}
+		n++
+		sb.WriteByte('$')
+		sb.WriteString(strconv.Itoa(n))
+	}
+	return sb.String()
+}
+
+// labelExpr возвращает SQL-выражение для значения метки из JSON в колонке labels.
+// Имя метки подставляется в запрос, поэтому сюда передаются только константы
+func (d dialect) labelExpr(label string) string {
+	if d.numbered {
+		return "(labels::jsonb ->> '" + label + "')"
+	}
+	return "json_extract(labels, '$." + label + "')"
+}
+
+// dbTimeLayout совпадает с форматом CURRENT_TIMESTAMP в SQLite, поэтому время в базе можно сравнивать как строки
+const dbTimeLayout = "2006-01-02 15:04:05.000"
+
+// time приводит время к UTC перед записью в базу. SQLite хранит его строкой в едином формате,
+// Postgres — в колонках TIMESTAMPTZ
+func (d dialect) time(t time.Time) any {
+	if d.numbered {
+		return t.UTC()
+	}
+	return t.UTC().Format(dbTimeLayout)
+}
+
+// nullTime записывает нулевое время как NULL
+func (d dialect) nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return d.time(t)
+}
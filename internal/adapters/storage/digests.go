@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"time"
+)
+
+const digestColumns = `chat_id, period, weekday, hour, minute, created_at, last_sent_at`
+
+func scanDigest(row rowScanner) (domain.DigestSchedule, error) {
+	var s domain.DigestSchedule
+	var weekday int
+	var lastSent sql.NullTime
+	err := row.Scan(&s.ChatID, &s.Period, &weekday, &s.Hour, &s.Minute, &s.CreatedAt, &lastSent)
+	s.Weekday = time.Weekday(weekday)
+	s.LastSentAt = lastSent.Time
+	return s, err
+}
+
+func (r *SQLRepo) GetDigestSchedules() ([]domain.DigestSchedule, error) {
+	rows, err := r.query(`SELECT ` + digestColumns + ` FROM digest_schedules ORDER BY chat_id`)
+	if err != nil {
+		slog.Error("Ошибка выборки расписаний сводок", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []domain.DigestSchedule
+	for rows.Next() {
+		s, err := scanDigest(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, rows.Err()
+}
+
+func (r *SQLRepo) GetDigestSchedule(chatID int64) (*domain.DigestSchedule, error) {
+	s, err := scanDigest(r.queryRow(`SELECT `+digestColumns+` FROM digest_schedules WHERE chat_id = ?`, chatID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Ошибка чтения расписания сводки", "chatID", chatID, "error", err)
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// SaveDigestSchedule сохраняет расписание. Время последней отправки при изменении расписания не сбрасывается
+func (r *SQLRepo) SaveDigestSchedule(schedule domain.DigestSchedule) error {
+	_, err := r.exec(`
+        INSERT INTO digest_schedules (chat_id, period, weekday, hour, minute, created_at) VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT (chat_id) DO UPDATE SET
+            period = excluded.period, weekday = excluded.weekday, hour = excluded.hour, minute = excluded.minute`,
+		schedule.ChatID, schedule.Period, int(schedule.Weekday), schedule.Hour, schedule.Minute, r.dialect.time(schedule.CreatedAt),
+	)
+	if err != nil {
+		slog.Error("Не удалось сохранить расписание сводки", "chatID", schedule.ChatID, "error", err)
+	}
+
+	return err
+}
+
+func (r *SQLRepo) DeleteDigestSchedule(chatID int64) error {
+	_, err := r.exec(`DELETE FROM digest_schedules WHERE chat_id = ?`, chatID)
+	return err
+}
+
+func (r *SQLRepo) MarkDigestSent(chatID int64, at time.Time) error {
+	_, err := r.exec(`UPDATE digest_schedules SET last_sent_at = ? WHERE chat_id = ?`, r.dialect.time(at), chatID)
+	return err
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"encoding/json"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"time"
+)
+
+func (r *SQLRepo) GetEscalationPolicies() ([]domain.EscalationPolicy, error) {
+	rows, err := r.query(`SELECT namespace, timeout_seconds, chat_ids, user_ids FROM escalation_policies ORDER BY namespace`)
+	if err != nil {
+		slog.Error("Ошибка выборки политик эскалации", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []domain.EscalationPolicy
+	for rows.Next() {
+		var p domain.EscalationPolicy
+		var timeout int64
+		var chatIDs, userIDs string
+		if err = rows.Scan(&p.Namespace, &timeout, &chatIDs, &userIDs); err != nil {
+			return nil, err
+		}
+		p.Timeout = time.Duration(timeout) * time.Second
+		if err = json.Unmarshal([]byte(chatIDs), &p.ChatIDs); err != nil {
+			slog.Error("Ошибка десериализации чатов эскалации", "namespace", p.Namespace, "error", err)
+		}
+		if err = json.Unmarshal([]byte(userIDs), &p.UserIDs); err != nil {
+			slog.Error("Ошибка десериализации пользователей эскалации", "namespace", p.Namespace, "error", err)
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *SQLRepo) SaveEscalationPolicy(policy domain.EscalationPolicy) error {
+	chatIDs, err := json.Marshal(policy.ChatIDs)
+	if err != nil {
+		return err
+	}
+	userIDs, err := json.Marshal(policy.UserIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`
+        INSERT INTO escalation_policies (namespace, timeout_seconds, chat_ids, user_ids) VALUES (?, ?, ?, ?)
+        ON CONFLICT (namespace) DO UPDATE SET
+            timeout_seconds = excluded.timeout_seconds, chat_ids = excluded.chat_ids, user_ids = excluded.user_ids`,
+		policy.Namespace, int64(policy.Timeout/time.Second), string(chatIDs), string(userIDs),
+	)
+	if err != nil {
+		slog.Error("Не удалось сохранить политику эскалации", "namespace", policy.Namespace, "error", err)
+	}
+
+	return err
+}
+
+func (r *SQLRepo) DeleteEscalationPolicy(namespace string) error {
+	_, err := r.exec(`DELETE FROM escalation_policies WHERE namespace = ?`, namespace)
+	return err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"strings"
+)
+
+func (r *SQLRepo) FindAlerts(filter domain.AlertFilter) ([]domain.Alert, error) {
+	var conds []string
+	var args []any
+	where := func(cond string, arg any) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+
+	if len(filter.Namespaces) > 0 {
+		conds = append(conds, "namespace IN (?"+strings.Repeat(", ?", len(filter.Namespaces)-1)+")")
+		for _, ns := range filter.Namespaces {
+			args = append(args, ns)
+		}
+	}
+	if filter.Deployment != "" {
+		where("deployment = ?", filter.Deployment)
+	}
+	if filter.Alertname != "" {
+		where(r.dialect.labelExpr("alertname")+" = ?", filter.Alertname)
+	}
+	if filter.Pod != "" {
+		where(r.dialect.labelExpr("pod")+" = ?", filter.Pod)
+	}
+	if filter.Fingerprint != "" {
+		where("fingerprint = ?", filter.Fingerprint)
+	}
+	switch filter.Status {
+	case domain.StatusResolved:
+		where("status = ?", domain.StatusResolved)
+	case domain.StatusFiring:
+		where("status <> ?", domain.StatusResolved)
+	}
+	if !filter.From.IsZero() {
+		where("COALESCE(starts_at, created_at) >= ?", r.dialect.time(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where("COALESCE(starts_at, created_at) < ?", r.dialect.time(filter.To))
+	}
+
+	query := `SELECT ` + alertColumns + ` FROM alerts`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY id DESC`
+	switch {
+	case filter.Limit == domain.NoAlertLimit:
+	case filter.Limit <= 0:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, domain.DefaultAlertLimit, filter.Offset)
+	default:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := r.query(query, args...)
+	if err != nil {
+		slog.Error("Ошибка поиска алертов", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []domain.Alert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
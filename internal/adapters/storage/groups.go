@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"time"
+)
+
+// WriteGroupedAlert записывает алерт и атомарно определяет его группу: если группа с ключом
+// alert.GroupKey открыта не раньше now-window, алерт присоединяется к ней, иначе открывает новую.
+// Возвращает ID алерта и ID первого алерта группы
+func (r *SQLRepo) WriteGroupedAlert(alert domain.Alert, namespace string, window time.Duration, now time.Time) (int64, int64, error) {
+	alert.GroupID = 0
+	query, args, err := r.insertAlertQuery(alert, namespace)
+	if err != nil {
+		slog.Error("Не удалось сериализовать алерт", "error", err)
+		return 0, 0, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err = tx.QueryRow(r.dialect.rebind(query+" RETURNING id"), args...).Scan(&id); err != nil {
+		return 0, 0, err
+	}
+
+	// Строка группы блокируется upsert'ом, поэтому одновременные алерты не откроют две группы
+	cutoff := r.dialect.time(now.Add(-window))
+	var leaderID int64
+	err = tx.QueryRow(r.dialect.rebind(`
+        INSERT INTO alert_groups (group_key, leader_id, opened_at) VALUES (?, ?, ?)
+        ON CONFLICT (group_key) DO UPDATE SET
+            leader_id = CASE WHEN alert_groups.opened_at < ? THEN excluded.leader_id ELSE alert_groups.leader_id END,
+            opened_at = CASE WHEN alert_groups.opened_at < ? THEN excluded.opened_at ELSE alert_groups.opened_at END
+        RETURNING leader_id`),
+		alert.GroupKey, id, r.dialect.time(now), cutoff, cutoff,
+	).Scan(&leaderID)
+	if err != nil {
+		slog.Error("Не удалось определить группу алерта", "key", alert.GroupKey, "error", err)
+		return 0, 0, err
+	}
+
+	if leaderID != id {
+		if _, err = tx.Exec(r.dialect.rebind(`UPDATE alerts SET group_id = ? WHERE id = ?`), leaderID, id); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return id, leaderID, tx.Commit()
+}
+
+// GetGroupAlerts возвращает все алерты группы, начиная с первого
+func (r *SQLRepo) GetGroupAlerts(leaderID int64) ([]domain.Alert, error) {
+	rows, err := r.query(`
+        SELECT `+alertColumns+`
+        FROM alerts
+        WHERE id = ? OR group_id = ?
+        ORDER BY id
+    `, leaderID, leaderID)
+	if err != nil {
+		slog.Error("Ошибка выборки алертов группы", "leaderID", leaderID, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []domain.Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+
+	return alerts, rows.Err()
+}
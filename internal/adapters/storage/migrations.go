@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migration одно изменение схемы. Версии идут по порядку и никогда не меняются после релиза:
+// чтобы поменять схему, добавьте новую миграцию в конец списка
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// execSQL миграция из готового SQL
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// Миграции SQLite. До появления schema_version схема создавалась через CREATE TABLE IF NOT EXISTS
+// и добавление недостающих колонок, поэтому первые миграции идемпотентны
+// и спокойно применяются к старым файлам alerts.db.
+// Изменения схемы нужно добавлять и сюда, и в postgresMigrations
+var sqliteMigrations = []migration{
+	{1, "create_alerts", execSQL(`
+        CREATE TABLE IF NOT EXISTS alerts (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            namespace TEXT,
+            status TEXT,
+            labels TEXT,
+            summary    TEXT,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+	)},
+	{2, "alert_lifecycle", func(tx *sql.Tx) error {
+		err := ensureColumns(tx, "alerts", [][2]string{
+			{"fingerprint", "TEXT"},
+			{"starts_at", "DATETIME"},
+			{"ends_at", "DATETIME"},
+			{"updated_at", "DATETIME"},
+			{"deployment", "TEXT"},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            CREATE TABLE IF NOT EXISTS alert_messages (
+                alert_id   INTEGER NOT NULL,
+                chat_id    INTEGER NOT NULL,
+                message_id INTEGER NOT NULL,
+                PRIMARY KEY (alert_id, chat_id)
+            );
+            CREATE INDEX IF NOT EXISTS alerts_fingerprint_idx ON alerts (fingerprint)`,
+		)
+		return err
+	}},
+	{3, "create_subscriptions", execSQL(`
+        CREATE TABLE IF NOT EXISTS subscriptions (
+            chat_id    INTEGER NOT NULL,
+            namespace  TEXT NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (chat_id, namespace)
+        )`,
+	)},
+	{4, "create_users", execSQL(`
+        CREATE TABLE IF NOT EXISTS users (
+            id         INTEGER PRIMARY KEY,
+            name       TEXT,
+            role       TEXT NOT NULL,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+	)},
+	{5, "create_audit_log", execSQL(`
+        CREATE TABLE IF NOT EXISTS audit_log (
+            id         INTEGER PRIMARY KEY AUTOINCREMENT,
+            user_id    INTEGER NOT NULL,
+            user_name  TEXT NOT NULL DEFAULT '',
+            chat_id    INTEGER NOT NULL,
+            action     TEXT NOT NULL,
+            namespace  TEXT NOT NULL DEFAULT '',
+            deployment TEXT NOT NULL DEFAULT '',
+            params     TEXT NOT NULL DEFAULT '',
+            result     TEXT NOT NULL,
+            error      TEXT NOT NULL DEFAULT '',
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS audit_log_namespace_idx ON audit_log (namespace, id)`,
+	)},
+	{6, "alert_ack_and_escalation", func(tx *sql.Tx) error {
+		err := ensureColumns(tx, "alerts", [][2]string{
+			{"acked_by", "INTEGER"},
+			{"acked_by_name", "TEXT"},
+			{"acked_at", "DATETIME"},
+			{"escalation_level", "INTEGER NOT NULL DEFAULT 0"},
+			{"escalated_at", "DATETIME"},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            CREATE TABLE IF NOT EXISTS escalation_policies (
+                namespace       TEXT PRIMARY KEY,
+                timeout_seconds INTEGER NOT NULL,
+                chat_ids        TEXT NOT NULL DEFAULT '[]',
+                user_ids        TEXT NOT NULL DEFAULT '[]'
+            )`,
+		)
+		return err
+	}},
+	{7, "create_oncall", execSQL(`
+        CREATE TABLE IF NOT EXISTS oncall_schedules (
+            namespace      TEXT PRIMARY KEY,
+            user_ids       TEXT NOT NULL DEFAULT '[]',
+            start_at       DATETIME NOT NULL,
+            period_seconds INTEGER NOT NULL,
+            time_zone      TEXT NOT NULL DEFAULT ''
+        );
+        CREATE TABLE IF NOT EXISTS oncall_overrides (
+            id        INTEGER PRIMARY KEY AUTOINCREMENT,
+            namespace TEXT NOT NULL,
+            user_id   INTEGER NOT NULL,
+            starts_at DATETIME NOT NULL,
+            ends_at   DATETIME NOT NULL
+        )`,
+	)},
+	{8, "create_silences", func(tx *sql.Tx) error {
+		err := ensureColumns(tx, "alerts", [][2]string{
+			{"suppressed", "INTEGER NOT NULL DEFAULT 0"},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            CREATE TABLE IF NOT EXISTS silences (
+                id              INTEGER PRIMARY KEY AUTOINCREMENT,
+                matchers        TEXT NOT NULL,
+                created_by      INTEGER NOT NULL,
+                created_by_name TEXT NOT NULL DEFAULT '',
+                created_at      DATETIME NOT NULL,
+                expires_at      DATETIME NOT NULL
+            )`,
+		)
+		return err
+	}},
+	{9, "alert_payload", func(tx *sql.Tx) error {
+		return ensureColumns(tx, "alerts", [][2]string{
+			{"annotations", "TEXT"},
+			{"generator_url", "TEXT"},
+			{"silence_url", "TEXT"},
+			{"dashboard_url", "TEXT"},
+			{"panel_url", "TEXT"},
+			{"values_json", "TEXT"},
+			{"value_string", "TEXT"},
+			{"org_id", "INTEGER"},
+			{"raw", "TEXT"},
+		})
+	}},
+	{10, "create_digest_schedules", execSQL(`
+        CREATE TABLE digest_schedules (
+            chat_id      INTEGER PRIMARY KEY,
+            period       TEXT NOT NULL,
+            weekday      INTEGER NOT NULL DEFAULT 0,
+            hour         INTEGER NOT NULL,
+            minute       INTEGER NOT NULL,
+            created_at   DATETIME NOT NULL,
+            last_sent_at DATETIME
+        )`,
+	)},
+	{11, "create_alert_queue", execSQL(`
+        CREATE TABLE alert_queue (
+            id              INTEGER PRIMARY KEY AUTOINCREMENT,
+            payload         TEXT NOT NULL,
+            raw             TEXT,
+            fingerprint     TEXT NOT NULL DEFAULT '',
+            alert_id        INTEGER NOT NULL DEFAULT 0,
+            status          TEXT NOT NULL,
+            attempts        INTEGER NOT NULL DEFAULT 0,
+            next_attempt_at DATETIME NOT NULL,
+            locked_until    DATETIME,
+            last_error      TEXT NOT NULL DEFAULT '',
+            created_at      DATETIME NOT NULL
+        );
+        CREATE INDEX alert_queue_status_idx ON alert_queue (status, next_attempt_at);
+        CREATE INDEX alert_queue_fingerprint_idx ON alert_queue (fingerprint, id);
+        ALTER TABLE alerts ADD COLUMN queue_id INTEGER NOT NULL DEFAULT 0;
+        CREATE INDEX alerts_queue_id_idx ON alerts (queue_id)`,
+	)},
+	{12, "alert_groups", func(tx *sql.Tx) error {
+		err := ensureColumns(tx, "alerts", [][2]string{
+			{"group_key", "TEXT NOT NULL DEFAULT ''"},
+			{"group_id", "INTEGER NOT NULL DEFAULT 0"},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            CREATE TABLE IF NOT EXISTS alert_groups (
+                group_key TEXT PRIMARY KEY,
+                leader_id INTEGER NOT NULL,
+                opened_at DATETIME NOT NULL
+            );
+            CREATE INDEX IF NOT EXISTS alerts_group_id_idx ON alerts (group_id)`,
+		)
+		return err
+	}},
+}
+
+// migrate применяет к базе все миграции, версия которых больше записанной в schema_version.
+// Каждая миграция выполняется в своей транзакции вместе с записью ее версии
+func migrate(db *sql.DB, d dialect) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Несколько реплик бота могут стартовать одновременно, миграции выполняет только одна из них
+	if d.lockSQL != "" {
+		if _, err = conn.ExecContext(ctx, d.lockSQL); err != nil {
+			return fmt.Errorf("failed to lock schema: %w", err)
+		}
+		defer conn.ExecContext(ctx, d.unlockSQL)
+	}
+
+	_, err = conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_version (
+            version    INTEGER PRIMARY KEY,
+            name       TEXT NOT NULL,
+            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version: %w", err)
+	}
+
+	var current int
+	if err = conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range d.migrations {
+		if m.version <= current {
+			continue
+		}
+		if err = applyMigration(ctx, conn, d, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		slog.Info("Применена миграция схемы", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, d dialect, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = m.up(tx); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(d.rebind(`INSERT INTO schema_version (version, name) VALUES (?, ?)`), m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ensureColumns добавляет в таблицу недостающие колонки. Нужна миграциям, которые
+// применяются к базам, созданным до появления schema_version
+func ensureColumns(tx *sql.Tx, table string, columns [][2]string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			typ       string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err = rows.Scan(&cid, &name, &typ, &notNull, &dfltValue, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		if existing[c[0]] {
+			continue
+		}
+		if _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, c[0], c[1])); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", table, c[0], err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"time"
+)
+
+// GetOnCallSchedule возвращает расписание дежурств namespace или nil, если его нет
+func (r *SQLRepo) GetOnCallSchedule(namespace string) (*domain.OnCallSchedule, error) {
+	var userIDs, timeZone string
+	var period int64
+	s := domain.OnCallSchedule{Namespace: namespace}
+
+	err := r.queryRow(`SELECT user_ids, start_at, period_seconds, time_zone FROM oncall_schedules WHERE namespace = ?`, namespace).
+		Scan(&userIDs, &s.Start, &period, &timeZone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Ошибка чтения расписания дежурств", "namespace", namespace, "error", err)
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(userIDs), &s.UserIDs); err != nil {
+		slog.Error("Ошибка десериализации дежурных", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	s.Period = time.Duration(period) * time.Second
+	if timeZone != "" {
+		if s.Location, err = time.LoadLocation(timeZone); err != nil {
+			slog.Error("Неизвестная временная зона расписания дежурств", "namespace", namespace, "zone", timeZone, "error", err)
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
+func (r *SQLRepo) SaveOnCallSchedule(schedule domain.OnCallSchedule) error {
+	userIDs, err := json.Marshal(schedule.UserIDs)
+	if err != nil {
+		return err
+	}
+
+	var timeZone string
+	if schedule.Location != nil {
+		timeZone = schedule.Location.String()
+	}
+
+	_, err = r.exec(`
+        INSERT INTO oncall_schedules (namespace, user_ids, start_at, period_seconds, time_zone) VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT (namespace) DO UPDATE SET
+            user_ids = excluded.user_ids, start_at = excluded.start_at, period_seconds = excluded.period_seconds,
+            time_zone = excluded.time_zone`,
+		schedule.Namespace, string(userIDs), r.dialect.time(schedule.Start), int64(schedule.Period/time.Second), timeZone,
+	)
+	if err != nil {
+		slog.Error("Не удалось сохранить расписание дежурств", "namespace", schedule.Namespace, "error", err)
+	}
+
+	return err
+}
+
+func (r *SQLRepo) AddOnCallOverride(override domain.OnCallOverride) error {
+	_, err := r.exec(`
+        INSERT INTO oncall_overrides (namespace, user_id, starts_at, ends_at) VALUES (?, ?, ?, ?)`,
+		override.Namespace, override.UserID, r.dialect.time(override.From), r.dialect.time(override.To),
+	)
+	if err != nil {
+		slog.Error("Не удалось сохранить замену дежурного", "namespace", override.Namespace, "error", err)
+	}
+
+	return err
+}
+
+// GetOnCallOverrides возвращает замены, действующие в момент at, в порядке создания
+func (r *SQLRepo) GetOnCallOverrides(namespace string, at time.Time) ([]domain.OnCallOverride, error) {
+	rows, err := r.query(`
+        SELECT id, namespace, user_id, starts_at, ends_at
+        FROM oncall_overrides
+        WHERE namespace = ? AND starts_at <= ? AND ends_at > ?
+        ORDER BY id
+    `, namespace, r.dialect.time(at), r.dialect.time(at))
+	if err != nil {
+		slog.Error("Ошибка выборки замен дежурных", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []domain.OnCallOverride
+	for rows.Next() {
+		var o domain.OnCallOverride
+		if err = rows.Scan(&o.ID, &o.Namespace, &o.UserID, &o.From, &o.To); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Миграции Postgres. В отличие от файла alerts.db одну базу Postgres могут использовать
+// несколько реплик бота. Первая версия сразу создает схему, до которой SQLite дошла за миграции 1–9
+var postgresMigrations = []migration{
+	{1, "initial", execSQL(`
+        CREATE TABLE IF NOT EXISTS alerts (
+            id               BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+            namespace        TEXT,
+            deployment       TEXT,
+            status           TEXT,
+            labels           TEXT,
+            summary          TEXT,
+            fingerprint      TEXT,
+            starts_at        TIMESTAMPTZ,
+            ends_at          TIMESTAMPTZ,
+            created_at       TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+            updated_at       TIMESTAMPTZ,
+            acked_by         BIGINT,
+            acked_by_name    TEXT,
+            acked_at         TIMESTAMPTZ,
+            escalation_level INTEGER NOT NULL DEFAULT 0,
+            escalated_at     TIMESTAMPTZ,
+            suppressed       BOOLEAN NOT NULL DEFAULT FALSE,
+            annotations      TEXT,
+            generator_url    TEXT,
+            silence_url      TEXT,
+            dashboard_url    TEXT,
+            panel_url        TEXT,
+            values_json      TEXT,
+            value_string     TEXT,
+            org_id           INTEGER,
+            raw              TEXT
+        );
+        CREATE INDEX IF NOT EXISTS alerts_fingerprint_idx ON alerts (fingerprint);
+        CREATE INDEX IF NOT EXISTS alerts_namespace_idx ON alerts (namespace, created_at);
+
+        CREATE TABLE IF NOT EXISTS alert_messages (
+            alert_id   BIGINT NOT NULL,
+            chat_id    BIGINT NOT NULL,
+            message_id BIGINT NOT NULL,
+            PRIMARY KEY (alert_id, chat_id)
+        );
+
+        CREATE TABLE IF NOT EXISTS subscriptions (
+            chat_id    BIGINT NOT NULL,
+            namespace  TEXT NOT NULL,
+            created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY (chat_id, namespace)
+        );
+
+        CREATE TABLE IF NOT EXISTS users (
+            id         BIGINT PRIMARY KEY,
+            name       TEXT,
+            role       TEXT NOT NULL,
+            updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE TABLE IF NOT EXISTS audit_log (
+            id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+            user_id    BIGINT NOT NULL,
+            user_name  TEXT NOT NULL DEFAULT '',
+            chat_id    BIGINT NOT NULL,
+            action     TEXT NOT NULL,
+            namespace  TEXT NOT NULL DEFAULT '',
+            deployment TEXT NOT NULL DEFAULT '',
+            params     TEXT NOT NULL DEFAULT '',
+            result     TEXT NOT NULL,
+            error      TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS audit_log_namespace_idx ON audit_log (namespace, id);
+
+        CREATE TABLE IF NOT EXISTS escalation_policies (
+            namespace       TEXT PRIMARY KEY,
+            timeout_seconds BIGINT NOT NULL,
+            chat_ids        TEXT NOT NULL DEFAULT '[]',
+            user_ids        TEXT NOT NULL DEFAULT '[]'
+        );
+
+        CREATE TABLE IF NOT EXISTS oncall_schedules (
+            namespace      TEXT PRIMARY KEY,
+            user_ids       TEXT NOT NULL DEFAULT '[]',
+            start_at       TIMESTAMPTZ NOT NULL,
+            period_seconds BIGINT NOT NULL,
+            time_zone      TEXT NOT NULL DEFAULT ''
+        );
+        CREATE TABLE IF NOT EXISTS oncall_overrides (
+            id        BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+            namespace TEXT NOT NULL,
+            user_id   BIGINT NOT NULL,
+            starts_at TIMESTAMPTZ NOT NULL,
+            ends_at   TIMESTAMPTZ NOT NULL
+        );
+
+        CREATE TABLE IF NOT EXISTS silences (
+            id              BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+            matchers        TEXT NOT NULL,
+            created_by      BIGINT NOT NULL,
+            created_by_name TEXT NOT NULL DEFAULT '',
+            created_at      TIMESTAMPTZ NOT NULL,
+            expires_at      TIMESTAMPTZ NOT NULL
+        )`,
+	)},
+	{2, "create_digest_schedules", execSQL(`
+        CREATE TABLE digest_schedules (
+            chat_id      BIGINT PRIMARY KEY,
+            period       TEXT NOT NULL,
+            weekday      INTEGER NOT NULL DEFAULT 0,
+            hour         INTEGER NOT NULL,
+            minute       INTEGER NOT NULL,
+            created_at   TIMESTAMPTZ NOT NULL,
+            last_sent_at TIMESTAMPTZ
+        )`,
+	)},
+	{3, "create_alert_queue", execSQL(`
+        CREATE TABLE alert_queue (
+            id              BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+            payload         TEXT NOT NULL,
+            raw             TEXT,
+            fingerprint     TEXT NOT NULL DEFAULT '',
+            alert_id        BIGINT NOT NULL DEFAULT 0,
+            status          TEXT NOT NULL,
+            attempts        INTEGER NOT NULL DEFAULT 0,
+            next_attempt_at TIMESTAMPTZ NOT NULL,
+            locked_until    TIMESTAMPTZ,
+            last_error      TEXT NOT NULL DEFAULT '',
+            created_at      TIMESTAMPTZ NOT NULL
+        );
+        CREATE INDEX alert_queue_status_idx ON alert_queue (status, next_attempt_at);
+        CREATE INDEX alert_queue_fingerprint_idx ON alert_queue (fingerprint, id);
+        ALTER TABLE alerts ADD COLUMN queue_id BIGINT NOT NULL DEFAULT 0;
+        CREATE INDEX alerts_queue_id_idx ON alerts (queue_id)`,
+	)},
+	{4, "alert_groups", execSQL(`
+        ALTER TABLE alerts ADD COLUMN group_key TEXT NOT NULL DEFAULT '';
+        ALTER TABLE alerts ADD COLUMN group_id BIGINT NOT NULL DEFAULT 0;
+        CREATE INDEX alerts_group_id_idx ON alerts (group_id);
+        CREATE TABLE alert_groups (
+            group_key TEXT PRIMARY KEY,
+            leader_id BIGINT NOT NULL,
+            opened_at TIMESTAMPTZ NOT NULL
+        )`,
+	)},
+}
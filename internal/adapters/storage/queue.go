@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+const (
+	queueStatusPending = "pending"
+	queueStatusFailed  = "failed"
+)
+
+func (r *SQLRepo) EnqueueAlerts(alerts []domain.Alert, at time.Time) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := r.dialect.rebind(`
+        INSERT INTO alert_queue (payload, raw, fingerprint, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	for _, a := range alerts {
+		payload, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		var raw any
+		if len(a.Raw) > 0 {
+			raw = string(a.Raw)
+		}
+		fingerprint := a.Fingerprint
+		if fingerprint == "" {
+			fingerprint = a.Labels.Fingerprint()
+		}
+		_, err = tx.Exec(query, string(payload), raw, fingerprint, queueStatusPending, r.dialect.time(at), r.dialect.time(at))
+		if err != nil {
+			slog.Error("Не удалось поставить алерт в очередь", "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepo) ClaimQueuedAlerts(now time.Time, lease time.Duration, limit int) ([]domain.QueuedAlert, error) {
+	// Алерт с тем же fingerprint не берется, пока в очереди есть более ранний: иначе повтор firing
+	// после resolved снова откроет инцидент. Условие на locked_until повторяется снаружи подзапроса,
+	// чтобы Postgres перепроверил его для строк, которые параллельно захватил другой экземпляр
+	rows, err := r.query(`
+        UPDATE alert_queue SET locked_until = ?, attempts = attempts + 1
+        WHERE id IN (
+            SELECT id FROM alert_queue q
+            WHERE status = ? AND next_attempt_at <= ? AND (locked_until IS NULL OR locked_until <= ?)
+              AND NOT EXISTS (
+                  SELECT 1 FROM alert_queue earlier
+                  WHERE earlier.fingerprint = q.fingerprint AND q.fingerprint <> ''
+                    AND earlier.id < q.id AND earlier.status = ?
+              )
+            ORDER BY id
+            LIMIT ?
+        ) AND (locked_until IS NULL OR locked_until <= ?)
+        RETURNING id, payload, raw, alert_id, attempts, last_error, created_at
+    `, r.dialect.time(now.Add(lease)), queueStatusPending, r.dialect.time(now), r.dialect.time(now),
+		queueStatusPending, limit, r.dialect.time(now))
+	if err != nil {
+		slog.Error("Ошибка выборки очереди алертов", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.QueuedAlert
+	for rows.Next() {
+		var item domain.QueuedAlert
+		var payload string
+		var raw sql.NullString
+		err = rows.Scan(&item.ID, &payload, &raw, &item.AlertID, &item.Attempts, &item.LastError, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(payload), &item.Alert); err != nil {
+			slog.Error("Ошибка десериализации алерта из очереди", "id", item.ID, "error", err)
+			if err = r.FailQueuedAlert(item.ID, err.Error()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		item.Alert.Raw = nil
+		if raw.Valid {
+			item.Alert.Raw = json.RawMessage(raw.String)
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не гарантирует порядок строк
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+// SetQueuedAlertID запоминает, что алерт уже записан в хранилище
+func (r *SQLRepo) SetQueuedAlertID(id, alertID int64) error {
+	_, err := r.exec(`UPDATE alert_queue SET alert_id = ? WHERE id = ?`, alertID, id)
+	return err
+}
+
+// FindQueuedAlert находит алерт, записанный прошлой попыткой, которая не успела сохранить его ID
+func (r *SQLRepo) FindQueuedAlert(id int64) (int64, error) {
+	var alertID int64
+	err := r.queryRow(`SELECT id FROM alerts WHERE queue_id = ? ORDER BY id DESC LIMIT 1`, id).Scan(&alertID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return alertID, err
+}
+
+func (r *SQLRepo) CompleteQueuedAlert(id int64) error {
+	_, err := r.exec(`DELETE FROM alert_queue WHERE id = ?`, id)
+	return err
+}
+
+func (r *SQLRepo) RetryQueuedAlert(id int64, next time.Time, lastErr string) error {
+	_, err := r.exec(`UPDATE alert_queue SET next_attempt_at = ?, locked_until = NULL, last_error = ? WHERE id = ?`,
+		r.dialect.time(next), lastErr, id)
+	return err
+}
+
+func (r *SQLRepo) FailQueuedAlert(id int64, lastErr string) error {
+	_, err := r.exec(`UPDATE alert_queue SET status = ?, locked_until = NULL, last_error = ? WHERE id = ?`,
+		queueStatusFailed, lastErr, id)
+	return err
+}
+
+func (r *SQLRepo) DeleteFailedQueuedAlerts(before time.Time) (int64, error) {
+	res, err := r.exec(`DELETE FROM alert_queue WHERE status = ? AND created_at < ?`, queueStatusFailed, r.dialect.time(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+func (r *SQLRepo) GetExpiredAlerts(policy domain.RetentionPolicy, now time.Time, limit int) ([]domain.Alert, error) {
+	var conds []string
+	var args []any
+	if policy.MaxAge > 0 {
+		conds = append(conds, "touched < ?")
+		args = append(args, r.dialect.time(now.Add(-policy.MaxAge)))
+	}
+	if policy.MaxPerNamespace > 0 {
+		conds = append(conds, "rn > ?")
+		args = append(args, policy.MaxPerNamespace)
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+	args = append(args, limit)
+
+	// Алерт, который продолжает приходить, обновляет updated_at, поэтому по возрасту удаляются только забытые
+	rows, err := r.query(`
+        SELECT `+alertColumns+`
+        FROM alerts
+        WHERE id IN (
+            SELECT id FROM (
+                SELECT id,
+                    COALESCE(updated_at, created_at) AS touched,
+                    ROW_NUMBER() OVER (PARTITION BY namespace ORDER BY id DESC) AS rn
+                FROM alerts
+            ) ranked
+            WHERE `+strings.Join(conds, " OR ")+`
+        )
+        ORDER BY id
+        LIMIT ?
+    `, args...)
+	if err != nil {
+		slog.Error("Ошибка выборки устаревших алертов", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []domain.Alert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func (r *SQLRepo) DeleteAlerts(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err = tx.Exec(r.dialect.rebind(`DELETE FROM alert_messages WHERE alert_id = ?`), id); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(r.dialect.rebind(`DELETE FROM alerts WHERE id = ?`), id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
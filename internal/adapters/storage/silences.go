@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"encoding/json"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+	"time"
+)
+
+func (r *SQLRepo) CreateSilence(silence domain.Silence) (int64, error) {
+	matchers, err := json.Marshal(silence.Matchers)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := r.insert(`
+        INSERT INTO silences (matchers, created_by, created_by_name, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		string(matchers), silence.CreatedBy, silence.CreatedByName, r.dialect.time(silence.CreatedAt), r.dialect.time(silence.ExpiresAt),
+	)
+	if err != nil {
+		slog.Error("Не удалось сохранить silence", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *SQLRepo) GetActiveSilences(at time.Time) ([]domain.Silence, error) {
+	rows, err := r.query(`
+        SELECT id, matchers, created_by, created_by_name, created_at, expires_at
+        FROM silences
+        WHERE expires_at > ?
+        ORDER BY id
+    `, r.dialect.time(at))
+	if err != nil {
+		slog.Error("Ошибка выборки silences", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var silences []domain.Silence
+	for rows.Next() {
+		var s domain.Silence
+		var matchers string
+		err = rows.Scan(&s.ID, &matchers, &s.CreatedBy, &s.CreatedByName, &s.CreatedAt, &s.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(matchers), &s.Matchers); err != nil {
+			slog.Error("Ошибка десериализации silence", "id", s.ID, "error", err)
+			continue
+		}
+		silences = append(silences, s)
+	}
+
+	return silences, rows.Err()
+}
+
+// ExpireSilence досрочно завершает silence
+func (r *SQLRepo) ExpireSilence(id int64, at time.Time) error {
+	_, err := r.exec(`UPDATE silences SET expires_at = ? WHERE id = ? AND expires_at > ?`, r.dialect.time(at), id, r.dialect.time(at))
+	return err
+}
@@ -4,38 +4,84 @@ import (
 	"database/sql"
 	_ "database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"hack-a-tone/internal/core/domain"
 	"log/slog"
+	"time"
 )
 
+// SQLRepo реализует хранилища бота поверх database/sql. Один и тот же код работает
+// с SQLite и Postgres, различия вынесены в dialect
 type SQLRepo struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
 }
 
-func NewSQLRepo() (*SQLRepo, error) {
-	db, err := sql.Open("sqlite3", "./alerts.db")
+// NewSQLRepo открывает базу по конфигурации, применяет к ней миграции и возвращает ошибку,
+// если база недоступна или схему не удалось обновить
+func NewSQLRepo(cfg Config) (*SQLRepo, error) {
+	d, err := cfg.dialect()
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := cfg.dsn(d)
 	if err != nil {
-		slog.Error("Не удалось открыть базу данных", "error", err)
 		return nil, err
 	}
 
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS alerts (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            namespace TEXT,
-            status TEXT,
-            labels TEXT,
-            summary    TEXT,
-            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-        )`,
-	)
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", d.name, err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	// SetMaxIdleConns(0) отключает простаивающие соединения, а не оставляет значение по умолчанию
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s database: %w", d.name, err)
+	}
+	if err = migrate(db, d); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate %s schema: %w", d.name, err)
+	}
+	slog.Info("База данных готова", "driver", d.name)
 
 	return &SQLRepo{
-		db: db,
+		db:      db,
+		dialect: d,
 	}, nil
 }
 
+func (r *SQLRepo) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLRepo) exec(query string, args ...any) (sql.Result, error) {
+	return r.db.Exec(r.dialect.rebind(query), args...)
+}
+
+func (r *SQLRepo) query(query string, args ...any) (*sql.Rows, error) {
+	return r.db.Query(r.dialect.rebind(query), args...)
+}
+
+func (r *SQLRepo) queryRow(query string, args ...any) *sql.Row {
+	return r.db.QueryRow(r.dialect.rebind(query), args...)
+}
+
+// insert выполняет INSERT ... RETURNING id и возвращает id новой строки.
+// LastInsertId не поддерживается драйвером Postgres, а RETURNING понимают обе базы
+func (r *SQLRepo) insert(query string, args ...any) (int64, error) {
+	var id int64
+	err := r.queryRow(query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
 func (r *SQLRepo) GetLastNAlerts(n int, namespaces []string) ([]domain.Alert, error) {
 	res := make([]domain.Alert, 0)
 
@@ -51,12 +97,140 @@ func (r *SQLRepo) GetLastNAlerts(n int, namespaces []string) ([]domain.Alert, er
 	return res, nil
 }
 
+const alertColumns = `id, namespace, deployment, status, labels, summary, fingerprint, starts_at, ends_at, acked_by, acked_by_name, acked_at,
+    escalation_level, escalated_at, suppressed, annotations, generator_url, silence_url, dashboard_url, panel_url,
+    values_json, value_string, org_id, raw, created_at, group_key, group_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAlert(row rowScanner) (domain.Alert, error) {
+	var (
+		id          int64
+		namespace   sql.NullString
+		deployment  sql.NullString
+		status      string
+		labelsJSON  string
+		summary     sql.NullString
+		fingerprint sql.NullString
+		startsAt    sql.NullTime
+		endsAt      sql.NullTime
+		ackedBy     sql.NullInt64
+		ackedByName sql.NullString
+		ackedAt     sql.NullTime
+		escLevel    int
+		escalatedAt sql.NullTime
+		suppressed  bool
+		annotations sql.NullString
+		generator   sql.NullString
+		silence     sql.NullString
+		dashboard   sql.NullString
+		panel       sql.NullString
+		valuesJSON  sql.NullString
+		valueString sql.NullString
+		orgID       sql.NullInt64
+		raw         sql.NullString
+		createdAt   sql.NullTime
+		groupKey    string
+		groupID     int64
+	)
+
+	err := row.Scan(&id, &namespace, &deployment, &status, &labelsJSON, &summary, &fingerprint, &startsAt, &endsAt,
+		&ackedBy, &ackedByName, &ackedAt, &escLevel, &escalatedAt, &suppressed, &annotations, &generator, &silence,
+		&dashboard, &panel, &valuesJSON, &valueString, &orgID, &raw, &createdAt, &groupKey, &groupID)
+	if err != nil {
+		return domain.Alert{}, err
+	}
+
+	var labels domain.Labels
+	err = json.Unmarshal([]byte(labelsJSON), &labels)
+	if err != nil {
+		slog.Error("Ошибка десериализации меток", "error", err)
+		return domain.Alert{}, err
+	}
+
+	// У алертов, записанных до появления колонки annotations, есть только summary
+	anns := domain.Annotations{Summary: summary.String}
+	if annotations.Valid {
+		if err = json.Unmarshal([]byte(annotations.String), &anns); err != nil {
+			slog.Error("Ошибка десериализации аннотаций", "id", id, "error", err)
+		}
+	}
+
+	var values map[string]interface{}
+	if valuesJSON.Valid {
+		if err = json.Unmarshal([]byte(valuesJSON.String), &values); err != nil {
+			slog.Error("Ошибка десериализации values", "id", id, "error", err)
+		}
+	}
+
+	var rawPayload json.RawMessage
+	if raw.Valid {
+		rawPayload = json.RawMessage(raw.String)
+	}
+
+	return domain.Alert{
+		ID:           id,
+		Namespace:    namespace.String,
+		Deployment:   deployment.String,
+		Status:       status,
+		Labels:       labels,
+		Annotations:  anns,
+		Fingerprint:  fingerprint.String,
+		StartsAt:     startsAt.Time,
+		EndsAt:       endsAt.Time,
+		GeneratorURL: generator.String,
+		SilenceURL:   silence.String,
+		DashboardURL: dashboard.String,
+		PanelURL:     panel.String,
+		Values:       values,
+		ValueString:  valueString.String,
+		OrgId:        int(orgID.Int64),
+		AckedBy:      ackedBy.Int64,
+		AckedByName:  ackedByName.String,
+		AckedAt:      ackedAt.Time,
+		CreatedAt:    createdAt.Time,
+		Raw:          rawPayload,
+		GroupKey:     groupKey,
+		GroupID:      groupID,
+
+		EscalationLevel: escLevel,
+		EscalatedAt:     escalatedAt.Time,
+		Suppressed:      suppressed,
+	}, nil
+}
+
+// alertPayload сериализует поля алерта, которые хранятся в базе как JSON
+func alertPayload(alert domain.Alert) (labels, annotations string, values, raw any, err error) {
+	labelsJSON, err := json.Marshal(alert.Labels)
+	if err != nil {
+		return "", "", nil, nil, fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	annotationsJSON, err := json.Marshal(alert.Annotations)
+	if err != nil {
+		return "", "", nil, nil, fmt.Errorf("failed to marshal annotations: %w", err)
+	}
+	if alert.Values != nil {
+		valuesJSON, err := json.Marshal(alert.Values)
+		if err != nil {
+			return "", "", nil, nil, fmt.Errorf("failed to marshal values: %w", err)
+		}
+		values = string(valuesJSON)
+	}
+	if len(alert.Raw) > 0 {
+		raw = string(alert.Raw)
+	}
+
+	return string(labelsJSON), string(annotationsJSON), values, raw, nil
+}
+
 func (r *SQLRepo) getLastNAlerts(n int, namespace string) ([]domain.Alert, error) {
-	rows, err := r.db.Query(`
-        SELECT status, labels, summary, created_at
+	rows, err := r.query(`
+        SELECT `+alertColumns+`
         FROM alerts
         WHERE namespace = ?
-        ORDER BY created_at DESC
+        ORDER BY created_at DESC, id DESC
         LIMIT ?
     `, namespace, n)
 	if err != nil {
@@ -68,30 +242,12 @@ func (r *SQLRepo) getLastNAlerts(n int, namespace string) ([]domain.Alert, error
 	var alerts []domain.Alert
 
 	for rows.Next() {
-		var status string
-		var labelsJSON string
-		var createdAt string
-		var summary string
-
-		err = rows.Scan(&status, &labelsJSON, &summary, &createdAt)
+		alert, err := scanAlert(rows)
 		if err != nil {
 			slog.Error("Ошибка чтения строки из базы", "error", err)
 			return nil, err
 		}
 
-		var labels domain.Labels
-		err = json.Unmarshal([]byte(labelsJSON), &labels)
-		if err != nil {
-			slog.Error("Ошибка десериализации меток", "error", err)
-			return nil, err
-		}
-
-		alert := domain.Alert{
-			Status:      status,
-			Labels:      labels,
-			Annotations: domain.Annotations{Summary: summary},
-		}
-
 		alerts = append(alerts, alert)
 	}
 
@@ -103,19 +259,169 @@ func (r *SQLRepo) getLastNAlerts(n int, namespace string) ([]domain.Alert, error
 	return alerts, nil
 }
 
-func (r *SQLRepo) WriteAlert(alert domain.Alert, namespace string) error {
+func (r *SQLRepo) WriteAlert(alert domain.Alert, namespace string) (int64, error) {
+	query, args, err := r.insertAlertQuery(alert, namespace)
+	if err != nil {
+		slog.Error("Не удалось сериализовать алерт", "error", err)
+		return 0, err
+	}
+
+	return r.insert(query, args...)
+}
+
+// insertAlertQuery собирает INSERT алерта без RETURNING
+func (r *SQLRepo) insertAlertQuery(alert domain.Alert, namespace string) (string, []any, error) {
 	alertDB := alert.ConvertToDB(namespace)
 
-	labelsJson, err := json.Marshal(alertDB.Labels)
+	labels, annotations, values, raw, err := alertPayload(alert)
 	if err != nil {
-		slog.Error("Не удалось сериализовать метки", "error", err)
+		return "", nil, err
+	}
+
+	args := []any{
+		alertDB.Namespace, alert.Deployment, alertDB.Status, labels, alert.Annotations.Summary,
+		alert.Fingerprint, r.dialect.nullTime(alert.StartsAt), r.dialect.nullTime(alert.EndsAt), alert.Suppressed,
+		annotations, alert.GeneratorURL, alert.SilenceURL, alert.DashboardURL, alert.PanelURL,
+		values, alert.ValueString, alert.OrgId, raw, alert.GroupKey, alert.GroupID, alert.QueueID,
+	}
+	return `
+        INSERT INTO alerts (namespace, deployment, status, labels, summary, fingerprint, starts_at, ends_at, suppressed,
+            annotations, generator_url, silence_url, dashboard_url, panel_url, values_json, value_string, org_id, raw,
+            group_key, group_id, queue_id, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`, args, nil
+}
+
+// GetAlert возвращает алерт по id или nil, если его нет
+func (r *SQLRepo) GetAlert(id int64) (*domain.Alert, error) {
+	row := r.queryRow(`SELECT `+alertColumns+` FROM alerts WHERE id = ?`, id)
+
+	alert, err := scanAlert(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Ошибка поиска алерта по id", "id", id, "error", err)
+		return nil, err
+	}
+
+	return &alert, nil
+}
+
+// GetOpenAlert возвращает последний неразрешенный алерт с таким fingerprint или nil, если его нет
+func (r *SQLRepo) GetOpenAlert(fingerprint string) (*domain.Alert, error) {
+	row := r.queryRow(`
+        SELECT `+alertColumns+`
+        FROM alerts
+        WHERE fingerprint = ? AND status <> ?
+        ORDER BY id DESC
+        LIMIT 1
+    `, fingerprint, domain.StatusResolved)
+
+	alert, err := scanAlert(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Ошибка поиска алерта по fingerprint", "fingerprint", fingerprint, "error", err)
+		return nil, err
+	}
+
+	return &alert, nil
+}
+
+func (r *SQLRepo) UpdateAlert(alert domain.Alert) error {
+	labels, annotations, values, raw, err := alertPayload(alert)
+	if err != nil {
+		slog.Error("Не удалось сериализовать алерт", "error", err)
 		return err
 	}
 
-	_, err = r.db.Exec(
-		"INSERT INTO alerts (namespace, status, labels, summary) VALUES (?, ?, ?, ?)",
-		alertDB.Namespace, alertDB.Status, string(labelsJson), alert.Annotations.Summary,
+	_, err = r.exec(`
+        UPDATE alerts
+        SET status = ?, labels = ?, summary = ?, starts_at = ?, ends_at = ?, annotations = ?, generator_url = ?,
+            silence_url = ?, dashboard_url = ?, panel_url = ?, values_json = ?, value_string = ?, org_id = ?, raw = ?,
+            queue_id = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?`,
+		alert.Status, labels, alert.Annotations.Summary, r.dialect.nullTime(alert.StartsAt), r.dialect.nullTime(alert.EndsAt),
+		annotations, alert.GeneratorURL, alert.SilenceURL, alert.DashboardURL, alert.PanelURL,
+		values, alert.ValueString, alert.OrgId, raw, alert.QueueID, alert.ID,
+	)
+
+	return err
+}
+
+// AckAlert отмечает алерт подтвержденным. Повторное подтверждение не перезаписывает первое
+func (r *SQLRepo) AckAlert(id int64, userID int64, userName string, at time.Time) error {
+	_, err := r.exec(`
+        UPDATE alerts
+        SET acked_by = ?, acked_by_name = ?, acked_at = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ? AND acked_at IS NULL`,
+		userID, userName, r.dialect.time(at), id,
+	)
+	if err != nil {
+		slog.Error("Не удалось подтвердить алерт", "id", id, "error", err)
+	}
+
+	return err
+}
+
+// GetUnackedAlerts возвращает активные алерты, которые никто не взял в работу
+func (r *SQLRepo) GetUnackedAlerts() ([]domain.Alert, error) {
+	rows, err := r.query(`
+        SELECT `+alertColumns+`
+        FROM alerts
+        WHERE status <> ? AND acked_at IS NULL AND NOT suppressed
+        ORDER BY id
+    `, domain.StatusResolved)
+	if err != nil {
+		slog.Error("Ошибка выборки неподтвержденных алертов", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []domain.Alert
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func (r *SQLRepo) SetEscalation(id int64, level int, at time.Time) error {
+	_, err := r.exec(`UPDATE alerts SET escalation_level = ?, escalated_at = ? WHERE id = ?`, level, r.dialect.time(at), id)
+	return err
+}
+
+func (r *SQLRepo) AddAlertMessage(msg domain.AlertMessage) error {
+	_, err := r.exec(`
+        INSERT INTO alert_messages (alert_id, chat_id, message_id) VALUES (?, ?, ?)
+        ON CONFLICT (alert_id, chat_id) DO UPDATE SET message_id = excluded.message_id`,
+		msg.AlertID, msg.ChatID, msg.MessageID,
 	)
 
 	return err
 }
+
+func (r *SQLRepo) GetAlertMessages(alertID int64) ([]domain.AlertMessage, error) {
+	rows, err := r.query(`SELECT alert_id, chat_id, message_id FROM alert_messages WHERE alert_id = ?`, alertID)
+	if err != nil {
+		slog.Error("Ошибка выборки сообщений алерта", "alertID", alertID, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []domain.AlertMessage
+	for rows.Next() {
+		var m domain.AlertMessage
+		if err = rows.Scan(&m.AlertID, &m.ChatID, &m.MessageID); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+
+	return msgs, rows.Err()
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"hack-a-tone/internal/adapters/storage/storagetest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteRepo(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Repo {
+		r, err := NewSQLRepo(Config{
+			DSN:         filepath.Join(t.TempDir(), "alerts.db"),
+			WAL:         true,
+			BusyTimeout: 5 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { r.Close() })
+		return r
+	})
+}
@@ -0,0 +1,631 @@
+// Package storagetest содержит общие тесты хранилищ, которые прогоняются
+// для каждой реализации: SQLite и Postgres
+package storagetest
+
+import (
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+	"hack-a-tone/internal/core/port"
+	"testing"
+	"time"
+)
+
+// Repo все хранилища, которые нужны боту
+type Repo interface {
+	port.AlertRepo
+	port.SubscriptionRepo
+	port.UserRepo
+	port.AuditRepo
+	port.EscalationRepo
+	port.OnCallRepo
+	port.SilenceRepo
+	port.RetentionRepo
+	port.DigestRepo
+	port.QueueRepo
+}
+
+// Run прогоняет набор тестов. newRepo должна возвращать хранилище с пустой базой
+func Run(t *testing.T, newRepo func(t *testing.T) Repo) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r Repo)
+	}{
+		{"AlertRoundTrip", testAlertRoundTrip},
+		{"OpenAlertLifecycle", testOpenAlertLifecycle},
+		{"AckAndEscalation", testAckAndEscalation},
+		{"AlertMessages", testAlertMessages},
+		{"LastNAlerts", testLastNAlerts},
+		{"FindAlerts", testFindAlerts},
+		{"Subscriptions", testSubscriptions},
+		{"Users", testUsers},
+		{"Audit", testAudit},
+		{"EscalationPolicies", testEscalationPolicies},
+		{"OnCall", testOnCall},
+		{"Silences", testSilences},
+		{"Retention", testRetention},
+		{"DigestSchedules", testDigestSchedules},
+		{"AlertQueue", testAlertQueue},
+		{"AlertGroups", testAlertGroups},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+// base время без долей секунды, чтобы оно одинаково сохранялось во всех базах
+var base = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newAlert(name, pod string) domain.Alert {
+	a := domain.Alert{
+		Status:       domain.StatusFiring,
+		StartsAt:     base,
+		GeneratorURL: "http://grafana/alerting/1",
+		DashboardURL: "http://grafana/d/1",
+		Values:       map[string]interface{}{"A": 1.5},
+		ValueString:  "[ var='A' value=1.5 ]",
+		OrgId:        1,
+		Deployment:   "api",
+	}
+	a.Labels.Set("alertname", name)
+	a.Labels.Set("pod", pod)
+	a.Labels.Set("team", "core")
+	a.Annotations.Set("summary", "summary of "+name)
+	a.Annotations.Set("runbook_url", "http://runbook")
+	a.Fingerprint = a.Labels.Fingerprint()
+	a.Raw = []byte(`{"status":"firing"}`)
+	return a
+}
+
+func mustWrite(t *testing.T, r Repo, a domain.Alert, ns string) int64 {
+	t.Helper()
+	id, err := r.WriteAlert(a, ns)
+	if err != nil {
+		t.Fatalf("WriteAlert: %v", err)
+	}
+	if id == 0 {
+		t.Fatal("WriteAlert returned zero id")
+	}
+	return id
+}
+
+func mustGet(t *testing.T, r Repo, id int64) domain.Alert {
+	t.Helper()
+	a, err := r.GetAlert(id)
+	if err != nil {
+		t.Fatalf("GetAlert(%d): %v", id, err)
+	}
+	if a == nil {
+		t.Fatalf("GetAlert(%d): not found", id)
+	}
+	return *a
+}
+
+func testAlertRoundTrip(t *testing.T, r Repo) {
+	in := newAlert("HighCPU", "api-1")
+	id := mustWrite(t, r, in, "prod")
+	got := mustGet(t, r, id)
+
+	if got.ID != id || got.Namespace != "prod" || got.Deployment != "api" || got.Status != in.Status {
+		t.Errorf("unexpected alert header: %+v", got)
+	}
+	if got.Labels.Get("team") != "core" || got.Labels.Alertname != "HighCPU" || got.Labels.Pod != "api-1" {
+		t.Errorf("labels not restored: %v", got.Labels.Map())
+	}
+	if got.Annotations.Summary != in.Annotations.Summary || got.Annotations.Get("runbook_url") != "http://runbook" {
+		t.Errorf("annotations not restored: %v", got.Annotations.Map())
+	}
+	if got.Fingerprint != in.Fingerprint || !got.StartsAt.Equal(base) || !got.EndsAt.IsZero() {
+		t.Errorf("fingerprint/timing not restored: %q %v %v", got.Fingerprint, got.StartsAt, got.EndsAt)
+	}
+	if got.GeneratorURL != in.GeneratorURL || got.DashboardURL != in.DashboardURL || got.ValueString != in.ValueString || got.OrgId != 1 {
+		t.Errorf("grafana fields not restored: %+v", got)
+	}
+	if got.Values["A"] != 1.5 {
+		t.Errorf("values not restored: %v", got.Values)
+	}
+	if string(got.Raw) != string(in.Raw) {
+		t.Errorf("raw payload not restored: %s", got.Raw)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("created_at is not read")
+	}
+
+	missing, err := r.GetAlert(id + 100)
+	if err != nil || missing != nil {
+		t.Errorf("GetAlert of missing id = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testOpenAlertLifecycle(t *testing.T, r Repo) {
+	a := newAlert("PodCrash", "web-1")
+	a.ID = mustWrite(t, r, a, "prod")
+
+	open, err := r.GetOpenAlert(a.Fingerprint)
+	if err != nil || open == nil || open.ID != a.ID {
+		t.Fatalf("GetOpenAlert = %v, %v; want alert %d", open, err, a.ID)
+	}
+
+	a.Status = domain.StatusResolved
+	a.EndsAt = base.Add(5 * time.Minute)
+	if err = r.UpdateAlert(a); err != nil {
+		t.Fatalf("UpdateAlert: %v", err)
+	}
+
+	got := mustGet(t, r, a.ID)
+	if !got.IsResolved() || got.Duration() != 5*time.Minute {
+		t.Errorf("alert not resolved: status %q, duration %v", got.Status, got.Duration())
+	}
+
+	open, err = r.GetOpenAlert(a.Fingerprint)
+	if err != nil || open != nil {
+		t.Errorf("GetOpenAlert after resolve = %v, %v; want nil", open, err)
+	}
+}
+
+func testAckAndEscalation(t *testing.T, r Repo) {
+	id := mustWrite(t, r, newAlert("Latency", "api-1"), "prod")
+
+	suppressed := newAlert("Noise", "api-2")
+	suppressed.Suppressed = true
+	mustWrite(t, r, suppressed, "prod")
+
+	unacked, err := r.GetUnackedAlerts()
+	if err != nil || len(unacked) != 1 || unacked[0].ID != id {
+		t.Fatalf("GetUnackedAlerts = %v, %v; want only alert %d", unacked, err, id)
+	}
+
+	if err = r.SetEscalation(id, 2, base.Add(time.Hour)); err != nil {
+		t.Fatalf("SetEscalation: %v", err)
+	}
+	got := mustGet(t, r, id)
+	if got.EscalationLevel != 2 || !got.EscalatedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("escalation = %d at %v", got.EscalationLevel, got.EscalatedAt)
+	}
+
+	if err = r.AckAlert(id, 10, "alice", base.Add(2*time.Hour)); err != nil {
+		t.Fatalf("AckAlert: %v", err)
+	}
+	// повторное подтверждение не перезаписывает первое
+	if err = r.AckAlert(id, 20, "bob", base.Add(3*time.Hour)); err != nil {
+		t.Fatalf("AckAlert: %v", err)
+	}
+	got = mustGet(t, r, id)
+	if got.AckedBy != 10 || got.AckedByName != "alice" || !got.AckedAt.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("ack = %d %q %v; want first ack", got.AckedBy, got.AckedByName, got.AckedAt)
+	}
+
+	unacked, err = r.GetUnackedAlerts()
+	if err != nil || len(unacked) != 0 {
+		t.Errorf("GetUnackedAlerts after ack = %v, %v", unacked, err)
+	}
+}
+
+func testAlertMessages(t *testing.T, r Repo) {
+	id := mustWrite(t, r, newAlert("Disk", "db-0"), "prod")
+
+	for _, m := range []domain.AlertMessage{
+		{AlertID: id, ChatID: -100123456789, MessageID: 1},
+		{AlertID: id, ChatID: 42, MessageID: 2},
+		{AlertID: id, ChatID: 42, MessageID: 3},
+	} {
+		if err := r.AddAlertMessage(m); err != nil {
+			t.Fatalf("AddAlertMessage: %v", err)
+		}
+	}
+
+	msgs, err := r.GetAlertMessages(id)
+	if err != nil {
+		t.Fatalf("GetAlertMessages: %v", err)
+	}
+	byChat := map[int64]int{}
+	for _, m := range msgs {
+		byChat[m.ChatID] = m.MessageID
+	}
+	if len(msgs) != 2 || byChat[-100123456789] != 1 || byChat[42] != 3 {
+		t.Errorf("messages = %v", msgs)
+	}
+}
+
+func testLastNAlerts(t *testing.T, r Repo) {
+	var ids []int64
+	for _, pod := range []string{"a", "b", "c"} {
+		ids = append(ids, mustWrite(t, r, newAlert("Err", pod), "prod"))
+	}
+	mustWrite(t, r, newAlert("Err", "x"), "stage")
+
+	got, err := r.GetLastNAlerts(2, []string{"prod"})
+	if err != nil {
+		t.Fatalf("GetLastNAlerts: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != ids[2] || got[1].ID != ids[1] {
+		t.Errorf("GetLastNAlerts = %v; want newest two of prod", got)
+	}
+
+	got, err = r.GetLastNAlerts(10, []string{"prod", "stage"})
+	if err != nil || len(got) != 4 {
+		t.Errorf("GetLastNAlerts for two namespaces = %d alerts, %v", len(got), err)
+	}
+}
+
+func testFindAlerts(t *testing.T, r Repo) {
+	cpu := newAlert("HighCPU", "api-1")
+	cpuID := mustWrite(t, r, cpu, "prod")
+
+	mem := newAlert("HighMemory", "web-1")
+	mem.Deployment = "web"
+	mem.StartsAt = base.Add(48 * time.Hour)
+	mem.Status = domain.StatusResolved
+	mem.EndsAt = mem.StartsAt.Add(time.Minute)
+	memID := mustWrite(t, r, mem, "prod")
+
+	stageID := mustWrite(t, r, newAlert("HighCPU", "api-1"), "stage")
+
+	tests := []struct {
+		name   string
+		filter domain.AlertFilter
+		want   []int64
+	}{
+		{"all", domain.AlertFilter{}, []int64{stageID, memID, cpuID}},
+		{"namespace", domain.AlertFilter{Namespaces: []string{"prod"}}, []int64{memID, cpuID}},
+		{"deployment", domain.AlertFilter{Deployment: "web"}, []int64{memID}},
+		{"alertname", domain.AlertFilter{Alertname: "HighCPU"}, []int64{stageID, cpuID}},
+		{"pod", domain.AlertFilter{Pod: "web-1"}, []int64{memID}},
+		{"fingerprint", domain.AlertFilter{Fingerprint: mem.Fingerprint}, []int64{memID}},
+		{"firing", domain.AlertFilter{Status: domain.StatusFiring, Namespaces: []string{"prod"}}, []int64{cpuID}},
+		{"resolved", domain.AlertFilter{Status: domain.StatusResolved}, []int64{memID}},
+		{"from", domain.AlertFilter{From: base.Add(time.Hour)}, []int64{memID}},
+		{"to", domain.AlertFilter{To: base.Add(time.Hour)}, []int64{stageID, cpuID}},
+		{"page", domain.AlertFilter{Limit: 1, Offset: 1}, []int64{memID}},
+	}
+	for _, tt := range tests {
+		got, err := r.FindAlerts(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: FindAlerts: %v", tt.name, err)
+		}
+		ids := make([]int64, len(got))
+		for i, a := range got {
+			ids[i] = a.ID
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: FindAlerts = %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
+
+func testSubscriptions(t *testing.T, r Repo) {
+	if err := r.AddNamespaces(1, []string{"prod", "stage"}); err != nil {
+		t.Fatalf("AddNamespaces: %v", err)
+	}
+	if err := r.AddNamespaces(1, []string{"prod"}); err != nil {
+		t.Fatalf("AddNamespaces duplicate: %v", err)
+	}
+	if err := r.AddNamespaces(-100123456789, []string{"prod"}); err != nil {
+		t.Fatalf("AddNamespaces: %v", err)
+	}
+	if err := r.RemoveNamespaces(1, []string{"stage"}); err != nil {
+		t.Fatalf("RemoveNamespaces: %v", err)
+	}
+
+	subs, err := r.GetSubscriptions()
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	if len(subs) != 2 || len(subs[1]) != 1 || subs[1][0] != "prod" || len(subs[-100123456789]) != 1 {
+		t.Errorf("subscriptions = %v", subs)
+	}
+}
+
+func testUsers(t *testing.T, r Repo) {
+	if u, err := r.GetUser(1); err != nil || u != nil {
+		t.Fatalf("GetUser of missing user = %v, %v", u, err)
+	}
+
+	if err := r.SaveUser(domain.User{ID: 1, Name: "alice", Role: domain.RoleViewer}); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	if err := r.SaveUser(domain.User{ID: 1, Name: "alice", Role: domain.RoleAdmin}); err != nil {
+		t.Fatalf("SaveUser update: %v", err)
+	}
+	if err := r.SaveUser(domain.User{ID: 2, Name: "bob", Role: domain.RoleOperator}); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+
+	u, err := r.GetUser(1)
+	if err != nil || u == nil || u.Role != domain.RoleAdmin {
+		t.Errorf("GetUser = %v, %v; want admin", u, err)
+	}
+
+	if err = r.DeleteUser(2); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	users, err := r.ListUsers()
+	if err != nil || len(users) != 1 || users[0].ID != 1 {
+		t.Errorf("ListUsers = %v, %v", users, err)
+	}
+}
+
+func testAudit(t *testing.T, r Repo) {
+	for i, action := range []string{domain.ActionRestartPod, domain.ActionScale} {
+		err := r.WriteAudit(domain.AuditEntry{
+			UserID: int64(i + 1), UserName: "alice", ChatID: 42, Action: action,
+			Namespace: "prod", Deployment: "api", Result: domain.AuditResultOK,
+		})
+		if err != nil {
+			t.Fatalf("WriteAudit: %v", err)
+		}
+	}
+
+	entries, err := r.GetLastNAudit(10, "prod")
+	if err != nil {
+		t.Fatalf("GetLastNAudit: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != domain.ActionScale || entries[0].CreatedAt.IsZero() {
+		t.Errorf("audit = %v", entries)
+	}
+}
+
+func testEscalationPolicies(t *testing.T, r Repo) {
+	policy := domain.EscalationPolicy{Namespace: "prod", Timeout: 15 * time.Minute, ChatIDs: []int64{-100123456789}, UserIDs: []int64{7}}
+	if err := r.SaveEscalationPolicy(policy); err != nil {
+		t.Fatalf("SaveEscalationPolicy: %v", err)
+	}
+	policy.Timeout = 30 * time.Minute
+	if err := r.SaveEscalationPolicy(policy); err != nil {
+		t.Fatalf("SaveEscalationPolicy update: %v", err)
+	}
+
+	policies, err := r.GetEscalationPolicies()
+	if err != nil || len(policies) != 1 {
+		t.Fatalf("GetEscalationPolicies = %v, %v", policies, err)
+	}
+	p := policies[0]
+	if p.Timeout != 30*time.Minute || len(p.ChatIDs) != 1 || p.ChatIDs[0] != -100123456789 || len(p.UserIDs) != 1 {
+		t.Errorf("policy = %+v", p)
+	}
+
+	if err = r.DeleteEscalationPolicy("prod"); err != nil {
+		t.Fatalf("DeleteEscalationPolicy: %v", err)
+	}
+	if policies, _ = r.GetEscalationPolicies(); len(policies) != 0 {
+		t.Errorf("policies after delete = %v", policies)
+	}
+}
+
+func testOnCall(t *testing.T, r Repo) {
+	if s, err := r.GetOnCallSchedule("prod"); err != nil || s != nil {
+		t.Fatalf("GetOnCallSchedule of missing schedule = %v, %v", s, err)
+	}
+
+	schedule := domain.OnCallSchedule{Namespace: "prod", UserIDs: []int64{1, 2}, Start: base, Period: domain.OnCallWeek}
+	if err := r.SaveOnCallSchedule(schedule); err != nil {
+		t.Fatalf("SaveOnCallSchedule: %v", err)
+	}
+	s, err := r.GetOnCallSchedule("prod")
+	if err != nil || s == nil || !s.Start.Equal(base) || s.Period != domain.OnCallWeek || len(s.UserIDs) != 2 {
+		t.Fatalf("GetOnCallSchedule = %+v, %v", s, err)
+	}
+
+	schedule.Location = time.UTC
+	if err = r.SaveOnCallSchedule(schedule); err != nil {
+		t.Fatalf("SaveOnCallSchedule: %v", err)
+	}
+	if s, err = r.GetOnCallSchedule("prod"); err != nil || s == nil || s.Location != time.UTC {
+		t.Fatalf("GetOnCallSchedule location = %+v, %v", s, err)
+	}
+
+	err = r.AddOnCallOverride(domain.OnCallOverride{Namespace: "prod", UserID: 3, From: base, To: base.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("AddOnCallOverride: %v", err)
+	}
+
+	overrides, err := r.GetOnCallOverrides("prod", base.Add(30*time.Minute))
+	if err != nil || len(overrides) != 1 || overrides[0].UserID != 3 {
+		t.Errorf("active overrides = %v, %v", overrides, err)
+	}
+	overrides, err = r.GetOnCallOverrides("prod", base.Add(2*time.Hour))
+	if err != nil || len(overrides) != 0 {
+		t.Errorf("expired overrides = %v, %v", overrides, err)
+	}
+}
+
+func testSilences(t *testing.T, r Repo) {
+	id, err := r.CreateSilence(domain.Silence{
+		Matchers:      map[string]string{"namespace": "prod", "alertname": "HighCPU"},
+		CreatedBy:     1,
+		CreatedByName: "alice",
+		CreatedAt:     base,
+		ExpiresAt:     base.Add(time.Hour),
+	})
+	if err != nil || id == 0 {
+		t.Fatalf("CreateSilence = %d, %v", id, err)
+	}
+
+	active, err := r.GetActiveSilences(base.Add(time.Minute))
+	if err != nil || len(active) != 1 || active[0].Matchers["alertname"] != "HighCPU" {
+		t.Fatalf("GetActiveSilences = %v, %v", active, err)
+	}
+
+	if err = r.ExpireSilence(id, base.Add(2*time.Minute)); err != nil {
+		t.Fatalf("ExpireSilence: %v", err)
+	}
+	active, err = r.GetActiveSilences(base.Add(3 * time.Minute))
+	if err != nil || len(active) != 0 {
+		t.Errorf("silences after expire = %v, %v", active, err)
+	}
+}
+
+func testRetention(t *testing.T, r Repo) {
+	var prod []int64
+	for _, pod := range []string{"a", "b", "c"} {
+		id := mustWrite(t, r, newAlert("Err", pod), "prod")
+		if err := r.AddAlertMessage(domain.AlertMessage{AlertID: id, ChatID: 1, MessageID: int(id)}); err != nil {
+			t.Fatalf("AddAlertMessage: %v", err)
+		}
+		prod = append(prod, id)
+	}
+	stage := mustWrite(t, r, newAlert("Err", "x"), "stage")
+
+	expired, err := r.GetExpiredAlerts(domain.RetentionPolicy{MaxPerNamespace: 2}, time.Now(), 10)
+	if err != nil || len(expired) != 1 || expired[0].ID != prod[0] {
+		t.Fatalf("expired by count = %v, %v; want oldest prod alert", expired, err)
+	}
+
+	expired, err = r.GetExpiredAlerts(domain.RetentionPolicy{MaxAge: time.Hour}, time.Now().Add(2*time.Hour), 10)
+	if err != nil || len(expired) != 4 {
+		t.Fatalf("expired by age = %d alerts, %v; want all", len(expired), err)
+	}
+	expired, err = r.GetExpiredAlerts(domain.RetentionPolicy{MaxAge: time.Hour}, time.Now(), 10)
+	if err != nil || len(expired) != 0 {
+		t.Fatalf("expired fresh alerts = %v, %v", expired, err)
+	}
+
+	if err = r.DeleteAlerts([]int64{prod[0], stage}); err != nil {
+		t.Fatalf("DeleteAlerts: %v", err)
+	}
+	if a, _ := r.GetAlert(prod[0]); a != nil {
+		t.Error("alert was not deleted")
+	}
+	if msgs, _ := r.GetAlertMessages(prod[0]); len(msgs) != 0 {
+		t.Errorf("messages of deleted alert = %v", msgs)
+	}
+	left, err := r.GetLastNAlerts(10, []string{"prod", "stage"})
+	if err != nil || len(left) != 2 {
+		t.Errorf("alerts left = %d, %v", len(left), err)
+	}
+}
+
+func testDigestSchedules(t *testing.T, r Repo) {
+	if s, err := r.GetDigestSchedule(42); err != nil || s != nil {
+		t.Fatalf("GetDigestSchedule of missing chat = %v, %v", s, err)
+	}
+
+	schedule := domain.DigestSchedule{ChatID: 42, Period: domain.DigestDaily, Hour: 9, Minute: 30, CreatedAt: base}
+	if err := r.SaveDigestSchedule(schedule); err != nil {
+		t.Fatalf("SaveDigestSchedule: %v", err)
+	}
+	if err := r.MarkDigestSent(42, base.Add(time.Hour)); err != nil {
+		t.Fatalf("MarkDigestSent: %v", err)
+	}
+	schedule.Period, schedule.Weekday = domain.DigestWeekly, time.Monday
+	if err := r.SaveDigestSchedule(schedule); err != nil {
+		t.Fatalf("SaveDigestSchedule update: %v", err)
+	}
+
+	s, err := r.GetDigestSchedule(42)
+	if err != nil || s == nil {
+		t.Fatalf("GetDigestSchedule = %v, %v", s, err)
+	}
+	if s.Period != domain.DigestWeekly || s.Weekday != time.Monday || s.Hour != 9 || s.Minute != 30 {
+		t.Errorf("schedule = %+v", s)
+	}
+	if !s.LastSentAt.Equal(base.Add(time.Hour)) || !s.CreatedAt.Equal(base) {
+		t.Errorf("schedule times = %v, %v", s.CreatedAt, s.LastSentAt)
+	}
+
+	if err = r.DeleteDigestSchedule(42); err != nil {
+		t.Fatalf("DeleteDigestSchedule: %v", err)
+	}
+	if all, _ := r.GetDigestSchedules(); len(all) != 0 {
+		t.Errorf("schedules after delete = %v", all)
+	}
+}
+
+func testAlertQueue(t *testing.T, r Repo) {
+	first, second, third := newAlert("A", "a-1"), newAlert("B", "b-1"), newAlert("C", "c-1")
+	if err := r.EnqueueAlerts([]domain.Alert{first, second, third}, base); err != nil {
+		t.Fatalf("EnqueueAlerts: %v", err)
+	}
+
+	lease := time.Minute
+	items, err := r.ClaimQueuedAlerts(base, lease, 2)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("ClaimQueuedAlerts = %v, %v", items, err)
+	}
+	got := items[0]
+	if got.Alert.Labels.Alertname != "A" || items[1].Alert.Labels.Alertname != "B" {
+		t.Errorf("claimed out of order: %+v", items)
+	}
+	if got.Attempts != 1 || got.AlertID != 0 || !got.CreatedAt.Equal(base) {
+		t.Errorf("claimed item = %+v", got)
+	}
+	if got.Alert.Fingerprint != first.Fingerprint || got.Alert.Labels.Get("team") != "core" ||
+		got.Alert.Annotations.Get("runbook_url") != "http://runbook" || string(got.Alert.Raw) != string(first.Raw) {
+		t.Errorf("payload not preserved: %+v", got.Alert)
+	}
+
+	// Захваченные алерты не отдаются повторно, пока не истек lease
+	items, err = r.ClaimQueuedAlerts(base, lease, 10)
+	if err != nil || len(items) != 1 || items[0].Alert.Labels.Alertname != "C" {
+		t.Fatalf("second claim = %v, %v", items, err)
+	}
+
+	if err = r.SetQueuedAlertID(got.ID, 7); err != nil {
+		t.Fatalf("SetQueuedAlertID: %v", err)
+	}
+	if err = r.RetryQueuedAlert(got.ID, base.Add(time.Hour), "telegram is down"); err != nil {
+		t.Fatalf("RetryQueuedAlert: %v", err)
+	}
+	if err = r.CompleteQueuedAlert(items[0].ID); err != nil {
+		t.Fatalf("CompleteQueuedAlert: %v", err)
+	}
+
+	// После истечения lease второй алерт возвращается, первый ждет своего времени повтора
+	items, err = r.ClaimQueuedAlerts(base.Add(2*lease), lease, 10)
+	if err != nil || len(items) != 1 || items[0].Alert.Labels.Alertname != "B" || items[0].Attempts != 2 {
+		t.Fatalf("claim after lease = %v, %v", items, err)
+	}
+	if err = r.FailQueuedAlert(items[0].ID, "broken"); err != nil {
+		t.Fatalf("FailQueuedAlert: %v", err)
+	}
+
+	items, err = r.ClaimQueuedAlerts(base.Add(2*time.Hour), lease, 10)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("claim after retry delay = %v, %v", items, err)
+	}
+	if items[0].ID != got.ID || items[0].AlertID != 7 || items[0].Attempts != 2 || items[0].LastError != "telegram is down" {
+		t.Errorf("retried item = %+v", items[0])
+	}
+}
+
+func testAlertGroups(t *testing.T, r Repo) {
+	write := func(pod string, at time.Time) (int64, int64) {
+		t.Helper()
+		a := newAlert("A", pod)
+		a.GroupKey = "alertname=A"
+		id, leaderID, err := r.WriteGroupedAlert(a, "prod", 5*time.Minute, at)
+		if err != nil {
+			t.Fatalf("WriteGroupedAlert(%s): %v", pod, err)
+		}
+		return id, leaderID
+	}
+
+	firstID, leaderID := write("a-1", base)
+	if leaderID != firstID {
+		t.Fatalf("first alert leader = %d, want %d", leaderID, firstID)
+	}
+	memberID, memberLeader := write("a-2", base.Add(time.Minute))
+	if memberLeader != firstID {
+		t.Errorf("member leader = %d, want %d", memberLeader, firstID)
+	}
+	mustWrite(t, r, newAlert("A", "a-3"), "prod")
+
+	// После окна алерт открывает новую группу
+	laterID, laterLeader := write("a-4", base.Add(10*time.Minute))
+	if laterLeader != laterID {
+		t.Errorf("alert after window leader = %d, want %d", laterLeader, laterID)
+	}
+
+	group, err := r.GetGroupAlerts(firstID)
+	if err != nil || len(group) != 2 {
+		t.Fatalf("GetGroupAlerts = %v, %v", group, err)
+	}
+	if group[0].ID != firstID || group[0].GroupLeaderID() != firstID || group[0].GroupKey != "alertname=A" {
+		t.Errorf("leader = %+v", group[0])
+	}
+	if group[1].ID != memberID || group[1].GroupID != firstID {
+		t.Errorf("member = %+v", group[1])
+	}
+}
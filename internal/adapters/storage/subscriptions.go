@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"log/slog"
+)
+
+func (r *SQLRepo) GetSubscriptions() (map[int64][]string, error) {
+	rows, err := r.query(`SELECT chat_id, namespace FROM subscriptions ORDER BY chat_id, created_at`)
+	if err != nil {
+		slog.Error("Ошибка выборки подписок из базы", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := map[int64][]string{}
+	for rows.Next() {
+		var chatID int64
+		var namespace string
+		if err = rows.Scan(&chatID, &namespace); err != nil {
+			slog.Error("Ошибка чтения подписки из базы", "error", err)
+			return nil, err
+		}
+		res[chatID] = append(res[chatID], namespace)
+	}
+
+	return res, rows.Err()
+}
+
+func (r *SQLRepo) AddNamespaces(chatID int64, namespaces []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ns := range namespaces {
+		_, err = tx.Exec(r.dialect.rebind(`
+            INSERT INTO subscriptions (chat_id, namespace) VALUES (?, ?)
+            ON CONFLICT (chat_id, namespace) DO NOTHING`),
+			chatID, ns,
+		)
+		if err != nil {
+			slog.Error("Не удалось сохранить подписку", "chatID", chatID, "namespace", ns, "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRepo) RemoveNamespaces(chatID int64, namespaces []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ns := range namespaces {
+		_, err = tx.Exec(r.dialect.rebind(`DELETE FROM subscriptions WHERE chat_id = ? AND namespace = ?`), chatID, ns)
+		if err != nil {
+			slog.Error("Не удалось удалить подписку", "chatID", chatID, "namespace", ns, "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
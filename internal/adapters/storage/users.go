@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"hack-a-tone/internal/core/domain"
+	"log/slog"
+)
+
+// GetUser возвращает пользователя из списка доступа или nil, если его там нет
+func (r *SQLRepo) GetUser(id int64) (*domain.User, error) {
+	var name, role string
+	err := r.queryRow(`SELECT name, role FROM users WHERE id = ?`, id).Scan(&name, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Ошибка чтения пользователя из базы", "id", id, "error", err)
+		return nil, err
+	}
+
+	parsed, _ := domain.ParseRole(role)
+	return &domain.User{ID: id, Name: name, Role: parsed}, nil
+}
+
+func (r *SQLRepo) SaveUser(user domain.User) error {
+	_, err := r.exec(`
+        INSERT INTO users (id, name, role, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+        ON CONFLICT (id) DO UPDATE SET name = excluded.name, role = excluded.role, updated_at = excluded.updated_at`,
+		user.ID, user.Name, user.Role.String(),
+	)
+	if err != nil {
+		slog.Error("Не удалось сохранить пользователя", "id", user.ID, "error", err)
+	}
+
+	return err
+}
+
+func (r *SQLRepo) DeleteUser(id int64) error {
+	_, err := r.exec(`DELETE FROM users WHERE id = ?`, id)
+	return err
+}
+
+func (r *SQLRepo) ListUsers() ([]domain.User, error) {
+	rows, err := r.query(`SELECT id, name, role FROM users ORDER BY id`)
+	if err != nil {
+		slog.Error("Ошибка выборки пользователей из базы", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []domain.User
+	for rows.Next() {
+		var u domain.User
+		var role string
+		if err = rows.Scan(&u.ID, &u.Name, &role); err != nil {
+			return nil, err
+		}
+		u.Role, _ = domain.ParseRole(role)
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"expvar"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Signature-Timestamp"
+)
+
+const defaultMetricsName = "webhook"
+
+const (
+	rejectNoCredentials    = "no_credentials"
+	rejectInvalidToken     = "invalid_token"
+	rejectInvalidBasicAuth = "invalid_basic_auth"
+	rejectInvalidSignature = "invalid_signature"
+	rejectInvalidTimestamp = "invalid_timestamp"
+)
+
+// AuthConfig способы проверки вебхука. Если задан и токен, и basic auth, подходит любой из них.
+// Подпись HMAC проверяется дополнительно, если задан HMACSecret
+type AuthConfig struct {
+	// Name префикс счетчиков <name>_accepted и <name>_rejected в /debug/vars, по умолчанию webhook
+	Name string
+
+	// BearerToken ожидается в заголовке Authorization: Bearer <token>
+	// (credentials в Alertmanager, Authorization header в Grafana)
+	BearerToken string
+	// BasicUser и BasicPassword для basic_auth Alertmanager и Grafana
+	BasicUser     string
+	BasicPassword string
+
+	// HMACSecret ключ подписи тела запроса HMAC-SHA256
+	HMACSecret string
+	// HMACHeader заголовок с подписью в hex, допускается префикс sha256=
+	HMACHeader string
+	// MaxSkew допустимое расхождение времени подписи с текущим. Если задано, отправитель передает
+	// unix-время в TimestampHeader и подписывает "<timestamp>.<body>", так перехваченный запрос
+	// нельзя повторить позже
+	MaxSkew         time.Duration
+	TimestampHeader string
+}
+
+func (c AuthConfig) Enabled() bool {
+	return c.BearerToken != "" || c.BasicUser != "" || c.HMACSecret != ""
+}
+
+// Auth проверяет запросы к вебхуку
+type Auth struct {
+	cfg     AuthConfig
+	now     func() time.Time
+	metrics authMetrics
+}
+
+// authMetrics принятые запросы и отклоненные по причинам
+type authMetrics struct {
+	accepted *expvar.Int
+	rejected *expvar.Map
+}
+
+var metricsMu sync.Mutex
+
+// newAuthMetrics регистрирует счетчики или возвращает уже зарегистрированные с тем же именем
+func newAuthMetrics(name string) authMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	var m authMetrics
+	if v, ok := expvar.Get(name + "_accepted").(*expvar.Int); ok {
+		m.accepted = v
+	} else {
+		m.accepted = expvar.NewInt(name + "_accepted")
+	}
+	if v, ok := expvar.Get(name + "_rejected").(*expvar.Map); ok {
+		m.rejected = v
+	} else {
+		m.rejected = expvar.NewMap(name + "_rejected")
+	}
+	return m
+}
+
+func NewAuth(cfg AuthConfig) *Auth {
+	if cfg.HMACHeader == "" {
+		cfg.HMACHeader = DefaultSignatureHeader
+	}
+	if cfg.TimestampHeader == "" {
+		cfg.TimestampHeader = DefaultTimestampHeader
+	}
+	if cfg.Name == "" {
+		cfg.Name = defaultMetricsName
+	}
+	return &Auth{cfg: cfg, now: time.Now, metrics: newAuthMetrics(cfg.Name)}
+}
+
+// Wrap пропускает к next только запросы, прошедшие проверку
+func (a *Auth) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reason, err := a.check(r)
+		if err != nil {
+			status := bodyErrorStatus(err)
+			slog.Warn("Не удалось прочитать тело запроса", "auth", a.cfg.Name, "error", err, "remote", r.RemoteAddr)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if reason != "" {
+			a.metrics.rejected.Add(reason, 1)
+			slog.Warn("Запрос отклонен", "auth", a.cfg.Name, "reason", reason, "remote", r.RemoteAddr, "path", r.URL.Path)
+			if a.cfg.BasicUser != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="alert webhook"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		a.metrics.accepted.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// check возвращает причину отказа или пустую строку. Ошибка означает, что не удалось прочитать тело
+func (a *Auth) check(r *http.Request) (string, error) {
+	if reason := a.checkCredentials(r); reason != "" {
+		return reason, nil
+	}
+	if a.cfg.HMACSecret == "" {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	// Тело уже прочитано, возвращаем его для следующего обработчика
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if a.cfg.MaxSkew > 0 {
+		timestamp := r.Header.Get(a.cfg.TimestampHeader)
+		if !a.validTimestamp(timestamp) {
+			return rejectInvalidTimestamp, nil
+		}
+		body = append([]byte(timestamp+"."), body...)
+	}
+	if !a.validSignature(body, r.Header.Get(a.cfg.HMACHeader)) {
+		return rejectInvalidSignature, nil
+	}
+	return "", nil
+}
+
+// validTimestamp проверяет, что время подписи в unix-секундах отличается от текущего не больше MaxSkew
+func (a *Auth) validTimestamp(timestamp string) bool {
+	sec, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := a.now().Sub(time.Unix(sec, 0))
+	return skew <= a.cfg.MaxSkew && skew >= -a.cfg.MaxSkew
+}
+
+func (a *Auth) checkCredentials(r *http.Request) string {
+	if a.cfg.BearerToken == "" && a.cfg.BasicUser == "" {
+		return ""
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return rejectNoCredentials
+	}
+
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		if a.cfg.BearerToken != "" && secureEqual(token, a.cfg.BearerToken) {
+			return ""
+		}
+		return rejectInvalidToken
+	}
+
+	user, password, ok := r.BasicAuth()
+	if ok && a.cfg.BasicUser != "" && secureEqual(user, a.cfg.BasicUser) && secureEqual(password, a.cfg.BasicPassword) {
+		return ""
+	}
+	return rejectInvalidBasicAuth
+}
+
+func (a *Auth) validSignature(body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(a.cfg.HMACSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
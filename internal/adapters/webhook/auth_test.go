@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBody = `{"alerts":[]}`
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuth(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name    string
+		cfg     AuthConfig
+		headers map[string]string
+		basic   []string
+		want    int
+		reason  string
+	}{
+		{
+			name: "no auth configured",
+			want: http.StatusOK,
+		},
+		{
+			name:    "valid bearer",
+			cfg:     AuthConfig{BearerToken: "secret"},
+			headers: map[string]string{"Authorization": "Bearer secret"},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "wrong bearer",
+			cfg:     AuthConfig{BearerToken: "secret"},
+			headers: map[string]string{"Authorization": "Bearer other"},
+			want:    http.StatusUnauthorized,
+			reason:  rejectInvalidToken,
+		},
+		{
+			name:   "missing credentials",
+			cfg:    AuthConfig{BearerToken: "secret"},
+			want:   http.StatusUnauthorized,
+			reason: rejectNoCredentials,
+		},
+		{
+			name:  "valid basic auth",
+			cfg:   AuthConfig{BasicUser: "am", BasicPassword: "pass"},
+			basic: []string{"am", "pass"},
+			want:  http.StatusOK,
+		},
+		{
+			name:   "wrong basic password",
+			cfg:    AuthConfig{BasicUser: "am", BasicPassword: "pass"},
+			basic:  []string{"am", "nope"},
+			want:   http.StatusUnauthorized,
+			reason: rejectInvalidBasicAuth,
+		},
+		{
+			name:  "basic auth accepted next to bearer",
+			cfg:   AuthConfig{BearerToken: "secret", BasicUser: "am", BasicPassword: "pass"},
+			basic: []string{"am", "pass"},
+			want:  http.StatusOK,
+		},
+		{
+			name:    "valid signature",
+			cfg:     AuthConfig{HMACSecret: "key"},
+			headers: map[string]string{DefaultSignatureHeader: sign("key", testBody)},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "signature with sha256 prefix",
+			cfg:     AuthConfig{HMACSecret: "key", HMACHeader: "X-Hub-Signature-256"},
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=" + sign("key", testBody)},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "signature of another body",
+			cfg:     AuthConfig{HMACSecret: "key"},
+			headers: map[string]string{DefaultSignatureHeader: sign("key", "{}")},
+			want:    http.StatusUnauthorized,
+			reason:  rejectInvalidSignature,
+		},
+		{
+			name:   "missing signature",
+			cfg:    AuthConfig{HMACSecret: "key"},
+			want:   http.StatusUnauthorized,
+			reason: rejectInvalidSignature,
+		},
+		{
+			name: "signature checked after token",
+			cfg:  AuthConfig{BearerToken: "secret", HMACSecret: "key"},
+			headers: map[string]string{
+				"Authorization":        "Bearer secret",
+				DefaultSignatureHeader: sign("other", testBody),
+			},
+			want:   http.StatusUnauthorized,
+			reason: rejectInvalidSignature,
+		},
+		{
+			name: "timestamp within window",
+			cfg:  AuthConfig{HMACSecret: "key", MaxSkew: 5 * time.Minute},
+			headers: map[string]string{
+				DefaultTimestampHeader: ts,
+				DefaultSignatureHeader: sign("key", ts+"."+testBody),
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "timestamp outside window",
+			cfg:  AuthConfig{HMACSecret: "key", MaxSkew: 5 * time.Minute},
+			headers: map[string]string{
+				DefaultTimestampHeader: stale,
+				DefaultSignatureHeader: sign("key", stale+"."+testBody),
+			},
+			want:   http.StatusUnauthorized,
+			reason: rejectInvalidTimestamp,
+		},
+		{
+			name:    "missing timestamp",
+			cfg:     AuthConfig{HMACSecret: "key", MaxSkew: 5 * time.Minute},
+			headers: map[string]string{DefaultSignatureHeader: sign("key", testBody)},
+			want:    http.StatusUnauthorized,
+			reason:  rejectInvalidTimestamp,
+		},
+		{
+			name: "timestamp not covered by signature",
+			cfg:  AuthConfig{HMACSecret: "key", MaxSkew: 5 * time.Minute},
+			headers: map[string]string{
+				DefaultTimestampHeader: ts,
+				DefaultSignatureHeader: sign("key", testBody),
+			},
+			want:   http.StatusUnauthorized,
+			reason: rejectInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewAuth(tt.cfg)
+			auth.now = func() time.Time { return now }
+
+			var gotBody string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				gotBody = string(b)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(testBody))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.basic != nil {
+				req.SetBasicAuth(tt.basic[0], tt.basic[1])
+			}
+
+			before := rejectedCount(auth, tt.reason)
+			rec := httptest.NewRecorder()
+			auth.Wrap(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && gotBody != testBody {
+				t.Errorf("next handler got body %q, want %q", gotBody, testBody)
+			}
+			if tt.reason != "" && rejectedCount(auth, tt.reason) != before+1 {
+				t.Errorf("rejected[%s] not incremented", tt.reason)
+			}
+		})
+	}
+}
+
+func TestAuthBodyTooLarge(t *testing.T) {
+	auth := NewAuth(AuthConfig{HMACSecret: "key"})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next handler called")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(testBody))
+	rec := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rec, req.Body, 4)
+	auth.Wrap(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestAuthMetricsPerName(t *testing.T) {
+	webhookAuth := NewAuth(AuthConfig{BearerToken: "secret"})
+	apiAuth := NewAuth(AuthConfig{Name: "api_test", BearerToken: "secret"})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	before := rejectedCount(webhookAuth, rejectNoCredentials)
+	apiAuth.Wrap(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+
+	if got := rejectedCount(apiAuth, rejectNoCredentials); got != 1 {
+		t.Errorf("api_test rejected = %d, want 1", got)
+	}
+	if got := rejectedCount(webhookAuth, rejectNoCredentials); got != before {
+		t.Errorf("webhook rejected changed: %d -> %d", before, got)
+	}
+	if again := NewAuth(AuthConfig{Name: "api_test"}); again.metrics.rejected != apiAuth.metrics.rejected {
+		t.Error("auth with the same name registered new counters")
+	}
+}
+
+func rejectedCount(auth *Auth, reason string) int64 {
+	if reason == "" {
+		return 0
+	}
+	v := auth.metrics.rejected.Get(reason)
+	if v == nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(v.String(), 10, 64)
+	return n
+}
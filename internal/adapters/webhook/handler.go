@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"errors"
+	"hack-a-tone/internal/core/domain"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// AlertSink принимает разобранные алерты. Ошибка означает, что алерты не сохранены
+// и отправитель должен повторить запрос
+type AlertSink interface {
+	AcceptAlerts(alerts domain.Alerts) error
+}
+
+// Handler принимает вебхуки Alertmanager, Grafana и голые массивы алертов: POST /alert
+type Handler struct {
+	sink AlertSink
+}
+
+func NewHandler(sink AlertSink) *Handler {
+	return &Handler{sink: sink}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		status := bodyErrorStatus(err)
+		slog.Warn("Не удалось прочитать тело вебхука", "error", err, "remote", r.RemoteAddr)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	slog.Debug("Получен вебхук", "body", string(body))
+
+	alerts, source, err := ParseAlerts(body)
+	if err != nil {
+		slog.Error("Не удалось разобрать вебхук", "error", err, "body", string(body))
+		http.Error(w, "invalid alert payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Info("Получены алерты", "source", source, "count", len(alerts))
+
+	if err = h.sink.AcceptAlerts(alerts); err != nil {
+		slog.Error("Не удалось принять алерты", "error", err, "count", len(alerts))
+		http.Error(w, "failed to accept alerts", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// bodyErrorStatus возвращает 413, если тело обрезано лимитом размера
+func bodyErrorStatus(err error) int {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"errors"
+	"hack-a-tone/internal/core/domain"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type sinkFunc func(alerts domain.Alerts) error
+
+func (f sinkFunc) AcceptAlerts(alerts domain.Alerts) error { return f(alerts) }
+
+func TestHandler(t *testing.T) {
+	const payload = `{"status":"firing","commonLabels":{"namespace":"prod"},"alerts":[{"labels":{"alertname":"A"}}]}`
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		sinkErr error
+		want    int
+		alerts  int
+	}{
+		{name: "accepted", method: http.MethodPost, body: payload, want: http.StatusAccepted, alerts: 1},
+		{name: "wrong method", method: http.MethodGet, want: http.StatusMethodNotAllowed},
+		{name: "empty body", method: http.MethodPost, want: http.StatusBadRequest},
+		{name: "invalid json", method: http.MethodPost, body: "{", want: http.StatusBadRequest},
+		{name: "sink unavailable", method: http.MethodPost, body: payload, sinkErr: errors.New("db is down"),
+			want: http.StatusServiceUnavailable, alerts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got domain.Alerts
+			h := NewHandler(sinkFunc(func(alerts domain.Alerts) error {
+				got = alerts
+				return tt.sinkErr
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/alert", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if len(got) != tt.alerts {
+				t.Fatalf("sink got %d alerts, want %d", len(got), tt.alerts)
+			}
+			if tt.alerts > 0 && (got[0].Status != "firing" || got[0].Labels.Get("namespace") != "prod") {
+				t.Errorf("alert not filled from envelope: %+v", got[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hack-a-tone/internal/core/domain"
+)
+
+type Source string
+
+const (
+	SourceRaw          Source = "raw"
+	SourceAlertmanager Source = "alertmanager"
+	SourceGrafana      Source = "grafana"
+)
+
+// envelope описывает общую часть вебхуков Alertmanager (version "4")
+// и Grafana unified alerting (version "1")
+type envelope struct {
+	Version           string             `json:"version"`
+	GroupKey          string             `json:"groupKey"`
+	TruncatedAlerts   int                `json:"truncatedAlerts"`
+	Status            string             `json:"status"`
+	Receiver          string             `json:"receiver"`
+	GroupLabels       domain.Labels      `json:"groupLabels"`
+	CommonLabels      domain.Labels      `json:"commonLabels"`
+	CommonAnnotations domain.Annotations `json:"commonAnnotations"`
+	ExternalURL       string             `json:"externalURL"`
+	Alerts            domain.Alerts      `json:"alerts"`
+
+	// Поля, которые присылает только Grafana
+	OrgID   *int   `json:"orgId"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	Message string `json:"message"`
+}
+
+func (e envelope) source() Source {
+	if e.OrgID != nil || e.State != "" || e.Title != "" || e.Version == "1" {
+		return SourceGrafana
+	}
+	return SourceAlertmanager
+}
+
+// ParseAlerts разбирает тело запроса на /alert. Поддерживается голый массив
+// алертов, вебхук Alertmanager и вебхук Grafana unified alerting.
+func ParseAlerts(body []byte) (domain.Alerts, Source, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, "", errors.New("empty alert body")
+	}
+
+	if body[0] == '[' {
+		var alerts domain.Alerts
+		if err := json.Unmarshal(body, &alerts); err != nil {
+			return nil, SourceRaw, fmt.Errorf("failed to unmarshal alerts array: %w", err)
+		}
+		return alerts, SourceRaw, nil
+	}
+
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal webhook envelope: %w", err)
+	}
+	if env.Alerts == nil {
+		return nil, "", errors.New("webhook payload has no alerts field")
+	}
+
+	src := env.source()
+	alerts := make(domain.Alerts, 0, len(env.Alerts))
+	for _, a := range env.Alerts {
+		alerts = append(alerts, env.fill(a, src))
+	}
+
+	return alerts, src, nil
+}
+
+// fill дополняет алерт данными из конверта, если в самом алерте их нет
+func (e envelope) fill(a domain.Alert, src Source) domain.Alert {
+	if a.Status == "" {
+		a.Status = e.Status
+	}
+
+	for k, v := range e.CommonLabels.Map() {
+		if a.Labels.Get(k) == "" {
+			a.Labels.Set(k, v)
+		}
+	}
+
+	for k, v := range e.CommonAnnotations.Map() {
+		if a.Annotations.Get(k) == "" {
+			a.Annotations.Set(k, v)
+		}
+	}
+
+	if src == SourceGrafana && a.OrgId == 0 && e.OrgID != nil {
+		a.OrgId = *e.OrgID
+	}
+
+	return a
+}
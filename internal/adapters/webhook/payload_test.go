@@ -0,0 +1,155 @@
+package webhook
+
+import "testing"
+
+func TestParseAlerts(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		source  Source
+		wantErr bool
+		check   func(t *testing.T, alerts []alertView)
+	}{
+		{
+			name: "alertmanager v4",
+			body: `{
+				"version": "4", "status": "firing", "receiver": "tg",
+				"groupLabels": {"alertname": "HighLatency"},
+				"commonLabels": {"alertname": "HighLatency", "namespace": "prod"},
+				"alerts": [
+					{"status": "firing", "labels": {"pod": "api-1"}, "fingerprint": "f1"},
+					{"status": "resolved", "labels": {"pod": "api-2"}, "fingerprint": "f2"}
+				]
+			}`,
+			source: SourceAlertmanager,
+			check: func(t *testing.T, alerts []alertView) {
+				want := []alertView{
+					{status: "firing", alertname: "HighLatency", namespace: "prod", pod: "api-1", fingerprint: "f1"},
+					{status: "resolved", alertname: "HighLatency", namespace: "prod", pod: "api-2", fingerprint: "f2"},
+				}
+				assertAlerts(t, alerts, want)
+			},
+		},
+		{
+			name: "grafana unified",
+			body: `{
+				"version": "1", "orgId": 3, "state": "alerting", "title": "[FIRING:1] DiskFull",
+				"status": "firing",
+				"alerts": [{"labels": {"alertname": "DiskFull", "grafana_folder": "infra"}}]
+			}`,
+			source: SourceGrafana,
+			check: func(t *testing.T, alerts []alertView) {
+				assertAlerts(t, alerts, []alertView{
+					{status: "firing", alertname: "DiskFull", folder: "infra", orgID: 3},
+				})
+			},
+		},
+		{
+			name:   "grafana detected by state without version",
+			body:   `{"state": "ok", "alerts": [{"status": "resolved", "labels": {"alertname": "A"}}]}`,
+			source: SourceGrafana,
+			check: func(t *testing.T, alerts []alertView) {
+				assertAlerts(t, alerts, []alertView{{status: "resolved", alertname: "A"}})
+			},
+		},
+		{
+			name:   "grafana orgId in alert wins",
+			body:   `{"orgId": 1, "alerts": [{"status": "firing", "orgId": 2, "labels": {"alertname": "A"}}]}`,
+			source: SourceGrafana,
+			check: func(t *testing.T, alerts []alertView) {
+				assertAlerts(t, alerts, []alertView{{status: "firing", alertname: "A", orgID: 2}})
+			},
+		},
+		{
+			name: "bare array",
+			body: `[
+				{"status": "firing", "labels": {"alertname": "A", "namespace": "dev"}},
+				{"status": "resolved", "labels": {"alertname": "B"}}
+			]`,
+			source: SourceRaw,
+			check: func(t *testing.T, alerts []alertView) {
+				assertAlerts(t, alerts, []alertView{
+					{status: "firing", alertname: "A", namespace: "dev"},
+					{status: "resolved", alertname: "B"},
+				})
+			},
+		},
+		{
+			name: "common labels and annotations do not override the alert",
+			body: `{
+				"status": "firing",
+				"commonLabels": {"alertname": "Common", "namespace": "prod", "team": "core"},
+				"commonAnnotations": {"summary": "common summary", "runbook_url": "http://runbook"},
+				"alerts": [{
+					"labels": {"alertname": "Own", "team": "payments"},
+					"annotations": {"summary": "own summary"}
+				}]
+			}`,
+			source: SourceAlertmanager,
+			check: func(t *testing.T, alerts []alertView) {
+				assertAlerts(t, alerts, []alertView{{
+					status: "firing", alertname: "Own", namespace: "prod", team: "payments",
+					summary: "own summary", runbook: "http://runbook",
+				}})
+			},
+		},
+		{name: "empty body", body: "  ", wantErr: true},
+		{name: "malformed envelope", body: `{"alerts": [`, wantErr: true},
+		{name: "malformed array", body: `[{"labels": 1}]`, source: SourceRaw, wantErr: true},
+		{name: "envelope without alerts", body: `{"status": "firing"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts, source, err := ParseAlerts([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAlerts error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if source != tt.source {
+				t.Errorf("source = %q, want %q", source, tt.source)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			views := make([]alertView, len(alerts))
+			for i, a := range alerts {
+				views[i] = alertView{
+					status:      a.Status,
+					alertname:   a.Labels.Alertname,
+					namespace:   a.Labels.Namespace,
+					pod:         a.Labels.Pod,
+					folder:      a.Labels.GrafanaFolder,
+					team:        a.Labels.Get("team"),
+					summary:     a.Annotations.Summary,
+					runbook:     a.Annotations.Get("runbook_url"),
+					fingerprint: a.Fingerprint,
+					orgID:       a.OrgId,
+				}
+				if len(a.Raw) == 0 {
+					t.Errorf("alert %d has no raw JSON", i)
+				}
+			}
+			tt.check(t, views)
+		})
+	}
+}
+
+// alertView поля алерта, которые проверяет тест
+type alertView struct {
+	status, alertname, namespace, pod, folder, team string
+	summary, runbook, fingerprint                   string
+	orgID                                           int
+}
+
+func assertAlerts(t *testing.T, got, want []alertView) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d alerts, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("alert %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,10 +1,19 @@
 package domain
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
 	"time"
 )
 
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
 type AlertDB struct {
 	Namespace string
 	Status    string
@@ -35,18 +44,244 @@ type Alert struct {
 	Values       map[string]interface{} `json:"values"`
 	ValueString  string                 `json:"valueString"`
 	OrgId        int                    `json:"orgId"`
+
+	// Поля ниже не приходят в вебхуке, их заполняет бот и хранилище
+	ID              int64     `json:"-"`
+	Namespace       string    `json:"-"`
+	Deployment      string    `json:"-"`
+	AckedBy         int64     `json:"-"`
+	AckedByName     string    `json:"-"`
+	AckedAt         time.Time `json:"-"`
+	EscalationLevel int       `json:"-"`
+	EscalatedAt     time.Time `json:"-"`
+	Suppressed      bool      `json:"-"`
+	CreatedAt       time.Time `json:"-"`
+	// GroupKey ключ группы алерта. У первого алерта группы GroupID равен 0, у остальных — ID первого
+	GroupKey string `json:"-"`
+	GroupID  int64  `json:"-"`
+	// QueueID строка очереди, из которой алерт записан или обновлен последний раз
+	QueueID int64 `json:"-"`
+	// Raw исходный JSON алерта из вебхука
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON разбирает алерт и сохраняет исходный JSON в Raw
+func (a *Alert) UnmarshalJSON(data []byte) error {
+	type plain Alert
+	if err := json.Unmarshal(data, (*plain)(a)); err != nil {
+		return err
+	}
+	a.Raw = append(json.RawMessage(nil), data...)
+	return nil
 }
 
 type Labels struct {
 	Alertname     string `json:"alertname"`
 	GrafanaFolder string `json:"grafana_folder"`
 	Pod           string `json:"pod"`
+	Namespace     string `json:"namespace"`
+	// Extra хранит все остальные метки алерта, которые не разобраны в поля выше
+	Extra map[string]string `json:"-"`
+}
+
+func (l *Labels) UnmarshalJSON(data []byte) error {
+	var all map[string]string
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+
+	*l = Labels{}
+	for k, v := range all {
+		l.Set(k, v)
+	}
+	return nil
+}
+
+func (l Labels) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Map())
+}
+
+// Get возвращает значение метки по имени, включая метки из Extra
+func (l Labels) Get(name string) string {
+	switch name {
+	case "alertname":
+		return l.Alertname
+	case "grafana_folder":
+		return l.GrafanaFolder
+	case "pod":
+		return l.Pod
+	case "namespace":
+		return l.Namespace
+	}
+	return l.Extra[name]
+}
+
+func (l *Labels) Set(name, value string) {
+	switch name {
+	case "alertname":
+		l.Alertname = value
+	case "grafana_folder":
+		l.GrafanaFolder = value
+	case "pod":
+		l.Pod = value
+	case "namespace":
+		l.Namespace = value
+	default:
+		if l.Extra == nil {
+			l.Extra = map[string]string{}
+		}
+		l.Extra[name] = value
+	}
+}
+
+// Map возвращает все непустые метки одной плоской картой
+func (l Labels) Map() map[string]string {
+	res := make(map[string]string, len(l.Extra)+4)
+	for k, v := range l.Extra {
+		res[k] = v
+	}
+	for _, k := range []string{"alertname", "grafana_folder", "pod", "namespace"} {
+		if v := l.Get(k); v != "" {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// Fingerprint считает отпечаток набора меток для алертов, пришедших без fingerprint
+func (l Labels) Fingerprint() string {
+	m := l.Map()
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0xff})
+		h.Write([]byte(m[k]))
+		h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
 }
 
 type Annotations struct {
-	Summary string `json:"summary"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	// Extra хранит остальные аннотации, например runbook_url
+	Extra map[string]string `json:"-"`
+}
+
+func (an *Annotations) UnmarshalJSON(data []byte) error {
+	var all map[string]string
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+
+	*an = Annotations{}
+	for k, v := range all {
+		an.Set(k, v)
+	}
+	return nil
+}
+
+func (an Annotations) Get(name string) string {
+	switch name {
+	case "summary":
+		return an.Summary
+	case "description":
+		return an.Description
+	}
+	return an.Extra[name]
+}
+
+func (an *Annotations) Set(name, value string) {
+	switch name {
+	case "summary":
+		an.Summary = value
+	case "description":
+		an.Description = value
+	default:
+		if an.Extra == nil {
+			an.Extra = map[string]string{}
+		}
+		an.Extra[name] = value
+	}
+}
+
+func (an Annotations) MarshalJSON() ([]byte, error) {
+	return json.Marshal(an.Map())
+}
+
+// Map возвращает все непустые аннотации одной плоской картой
+func (an Annotations) Map() map[string]string {
+	res := make(map[string]string, len(an.Extra)+2)
+	for k, v := range an.Extra {
+		res[k] = v
+	}
+	if an.Summary != "" {
+		res["summary"] = an.Summary
+	}
+	if an.Description != "" {
+		res["description"] = an.Description
+	}
+	return res
+}
+
+// GroupLeaderID возвращает ID первого алерта группы или 0, если алерт не сгруппирован
+func (a Alert) GroupLeaderID() int64 {
+	if a.GroupID != 0 {
+		return a.GroupID
+	}
+	if a.GroupKey != "" {
+		return a.ID
+	}
+	return 0
+}
+
+func (a Alert) IsAcked() bool {
+	return !a.AckedAt.IsZero()
+}
+
+func (a Alert) IsResolved() bool {
+	return strings.EqualFold(a.Status, StatusResolved)
+}
+
+// Duration возвращает длительность инцидента. Для активного алерта считается до текущего момента
+func (a Alert) Duration() time.Duration {
+	if a.StartsAt.IsZero() {
+		return 0
+	}
+	end := a.EndsAt
+	if !a.IsResolved() || end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(a.StartsAt).Round(time.Second)
 }
 
 func (a Alert) String() string {
-	return fmt.Sprintf("Alert: %s🚨\n\tPod: %s\n\tProblem: %s", a.Labels.Alertname, a.Labels.Pod, a.Annotations.Summary)
+	var res string
+	if a.IsResolved() {
+		res = fmt.Sprintf("Resolved: %s✅\n\tPod: %s\n\tProblem: %s\n\tDuration: %s",
+			a.Labels.Alertname, a.Labels.Pod, a.Annotations.Summary, a.Duration())
+	} else {
+		res = fmt.Sprintf("Alert: %s🚨\n\tPod: %s\n\tProblem: %s", a.Labels.Alertname, a.Labels.Pod, a.Annotations.Summary)
+	}
+
+	if a.Suppressed {
+		res += "\n\tSilenced 🔕"
+	}
+	if a.IsAcked() {
+		res += fmt.Sprintf("\n\tAck: %s (%s)", a.AckedByName, a.AckedAt.Local().Format("02.01 15:04"))
+	}
+	return res
+}
+
+// AlertMessage связывает алерт с сообщением, отправленным о нем в чат
+type AlertMessage struct {
+	AlertID   int64
+	ChatID    int64
+	MessageID int
 }
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ActionRestartDeployment = "restart_deployment"
+	ActionRestartPod        = "restart_pod"
+	ActionScale             = "scale"
+	ActionRollback          = "rollback"
+)
+
+const (
+	AuditResultOK    = "ok"
+	AuditResultError = "error"
+)
+
+// AuditEntry запись журнала о действии, изменяющем состояние кластера
+type AuditEntry struct {
+	ID         int64
+	UserID     int64
+	UserName   string
+	ChatID     int64
+	Action     string
+	Namespace  string
+	Deployment string
+	Params     string
+	Result     string
+	Error      string
+	CreatedAt  time.Time
+}
+
+func (e AuditEntry) String() string {
+	target := e.Namespace
+	if e.Deployment != "" {
+		target += "/" + e.Deployment
+	}
+
+	res := fmt.Sprintf("%s %s (%d): %s %s", e.CreatedAt.Local().Format("02.01 15:04:05"), e.UserName, e.UserID, e.Action, target)
+	if e.Params != "" {
+		res += " [" + e.Params + "]"
+	}
+	if e.Result == AuditResultOK {
+		return res + " ✅"
+	}
+	return res + " ❌ " + e.Error
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSchedule расписание сводки для чата. Время отправки задается в часовом поясе бота
+type DigestSchedule struct {
+	ChatID int64
+	Period string
+	// Weekday день отправки еженедельной сводки
+	Weekday    time.Weekday
+	Hour       int
+	Minute     int
+	CreatedAt  time.Time
+	LastSentAt time.Time
+}
+
+// Interval период, за который собирается сводка
+func (s DigestSchedule) Interval() time.Duration {
+	if s.Period == DigestWeekly {
+		return OnCallWeek
+	}
+	return 24 * time.Hour
+}
+
+// LastRun последнее плановое время отправки, не позже now
+func (s DigestSchedule) LastRun(now time.Time) time.Time {
+	if s.Period == DigestWeekly {
+		return LastHandover(now, s.Weekday, s.Hour, s.Minute)
+	}
+
+	t := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, s.Minute, 0, 0, now.Location())
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// Due сообщает, что плановое время наступило, а сводка за него еще не отправлена
+func (s DigestSchedule) Due(now time.Time) bool {
+	since := s.LastSentAt
+	if since.IsZero() {
+		since = s.CreatedAt
+	}
+	return s.LastRun(now).After(since)
+}
+
+func (s DigestSchedule) String() string {
+	if s.Period == DigestWeekly {
+		return fmt.Sprintf("еженедельно, %s в %02d:%02d", s.Weekday, s.Hour, s.Minute)
+	}
+	return fmt.Sprintf("ежедневно в %02d:%02d", s.Hour, s.Minute)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EscalationPolicy описывает эскалацию неподтвержденных алертов namespace.
+// Каждые Timeout без подтверждения бот повторяет уведомление: сначала в подписанные
+// чаты, а начиная со второго уровня — еще и в ChatIDs и лично пользователям UserIDs.
+type EscalationPolicy struct {
+	Namespace string
+	Timeout   time.Duration
+	ChatIDs   []int64
+	UserIDs   []int64
+}
+
+// Due сообщает, пора ли эскалировать алерт
+func (p EscalationPolicy) Due(a Alert, now time.Time) bool {
+	if p.Timeout <= 0 || a.IsResolved() || a.IsAcked() {
+		return false
+	}
+
+	last := a.EscalatedAt
+	if last.IsZero() {
+		last = a.StartsAt
+	}
+	return !last.IsZero() && now.Sub(last) >= p.Timeout
+}
+
+func (p EscalationPolicy) String() string {
+	res := fmt.Sprintf("%s: каждые %s", p.Namespace, p.Timeout)
+	var targets []string
+	for _, id := range p.ChatIDs {
+		targets = append(targets, fmt.Sprintf("chat:%d", id))
+	}
+	for _, id := range p.UserIDs {
+		targets = append(targets, fmt.Sprintf("user:%d", id))
+	}
+	if len(targets) > 0 {
+		res += ", затем " + strings.Join(targets, " ")
+	}
+	return res
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultAlertLimit = 50
+	MaxAlertLimit     = 500
+	// NoAlertLimit снимает ограничение на количество алертов, например для статистики
+	NoAlertLimit = -1
+)
+
+// AlertFilter условия выборки инцидентов. Пустые поля не ограничивают выборку
+type AlertFilter struct {
+	Namespaces  []string
+	Deployment  string
+	Alertname   string
+	Pod         string
+	Fingerprint string
+	Status      string
+	// From и To ограничивают время начала инцидента
+	From time.Time
+	To   time.Time
+
+	Limit  int
+	Offset int
+}
+
+// ParseAlertFilter разбирает фильтр из параметров namespace, deployment, alertname, pod, fingerprint,
+// status, since (например 24h), from и to (RFC3339 или 2006-01-02), limit и offset
+func ParseAlertFilter(values url.Values, now time.Time) (AlertFilter, error) {
+	f := AlertFilter{
+		Namespaces:  values["namespace"],
+		Deployment:  values.Get("deployment"),
+		Alertname:   values.Get("alertname"),
+		Pod:         values.Get("pod"),
+		Fingerprint: values.Get("fingerprint"),
+		Status:      values.Get("status"),
+		Limit:       DefaultAlertLimit,
+	}
+
+	if f.Status != "" && f.Status != StatusFiring && f.Status != StatusResolved {
+		return f, fmt.Errorf("status must be %s or %s", StatusFiring, StatusResolved)
+	}
+
+	var err error
+	if v := values.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return f, fmt.Errorf("invalid since %q", v)
+		}
+		f.From = now.Add(-d)
+	}
+	if v := values.Get("from"); v != "" {
+		if f.From, err = parseFilterTime(v); err != nil {
+			return f, err
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if f.To, err = parseFilterTime(v); err != nil {
+			return f, err
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return f, fmt.Errorf("invalid limit %q", v)
+		}
+		f.Limit = min(f.Limit, MaxAlertLimit)
+	}
+	if v := values.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			return f, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+
+	return f, nil
+}
+
+func parseFilterTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want RFC3339 or YYYY-MM-DD", v)
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AlertGrouping объединяет в одно сообщение алерты с одинаковыми значениями меток GroupBy,
+// которые пришли в течение Window после первого алерта группы
+type AlertGrouping struct {
+	GroupBy []string
+	Window  time.Duration
+}
+
+func (g AlertGrouping) Enabled() bool {
+	return len(g.GroupBy) > 0 && g.Window > 0
+}
+
+// Key считает ключ группы. Namespace входит в ключ всегда, потому что чаты подписываются на namespace.
+// Для deployment используется deployment, которому принадлежит под
+func (g AlertGrouping) Key(a Alert) string {
+	parts := make([]string, 0, len(g.GroupBy)+1)
+	parts = append(parts, "namespace="+a.Namespace)
+	for _, name := range g.GroupBy {
+		value := a.Labels.Get(name)
+		switch name {
+		case "namespace":
+			continue
+		case "deployment":
+			if a.Deployment != "" {
+				value = a.Deployment
+			}
+		}
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// AlertGroupSummary описывает состояние группы для сообщения в чат
+type AlertGroupSummary struct {
+	Alertname  string
+	Namespace  string
+	Deployment string
+	Summary    string
+	Firing     int
+	Resolved   int
+	Alerts     []Alert
+}
+
+func SummarizeGroup(alerts []Alert) AlertGroupSummary {
+	var s AlertGroupSummary
+	if len(alerts) == 0 {
+		return s
+	}
+
+	first := alerts[0]
+	s.Alertname, s.Namespace, s.Deployment, s.Summary = first.Labels.Alertname, first.Namespace, first.Deployment, first.Annotations.Summary
+	s.Alerts = append([]Alert(nil), alerts...)
+	sort.SliceStable(s.Alerts, func(i, j int) bool {
+		// Сначала активные, потом разрешенные
+		return !s.Alerts[i].IsResolved() && s.Alerts[j].IsResolved()
+	})
+	for _, a := range alerts {
+		if a.IsResolved() {
+			s.Resolved++
+		} else {
+			s.Firing++
+		}
+	}
+	return s
+}
+
+func (s AlertGroupSummary) IsResolved() bool {
+	return s.Firing == 0
+}
+
+func (s AlertGroupSummary) String() string {
+	var sb strings.Builder
+	if s.IsResolved() {
+		fmt.Fprintf(&sb, "Resolved: %s✅", s.Alertname)
+	} else {
+		fmt.Fprintf(&sb, "Alert: %s🚨", s.Alertname)
+	}
+	if s.Deployment != "" {
+		fmt.Fprintf(&sb, "\n\tDeployment: %s", s.Deployment)
+	}
+	fmt.Fprintf(&sb, "\n\tProblem: %s", s.Summary)
+	fmt.Fprintf(&sb, "\n\tPods: %d firing, %d resolved", s.Firing, s.Resolved)
+
+	for _, a := range s.Alerts {
+		pod := a.Labels.Pod
+		if pod == "" {
+			pod = a.Fingerprint
+		}
+		if a.IsResolved() {
+			fmt.Fprintf(&sb, "\n\t✅ %s (%s)", pod, a.Duration())
+			continue
+		}
+		line := "\n\t🚨 " + pod
+		if a.IsAcked() {
+			line += " — Ack: " + a.AckedByName
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
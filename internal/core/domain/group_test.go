@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func groupAlert(status, pod string) Alert {
+	a := Alert{Status: status, Namespace: "prod", Deployment: "api", Fingerprint: "fp-" + pod}
+	a.Labels.Set("alertname", "HighLatency")
+	a.Labels.Set("pod", pod)
+	a.Labels.Set("team", "core")
+	a.Annotations.Summary = "p99 above 1s"
+	return a
+}
+
+func TestAlertGroupingKey(t *testing.T) {
+	a := groupAlert(StatusFiring, "api-1")
+
+	tests := []struct {
+		name    string
+		groupBy []string
+		alert   Alert
+		want    string
+	}{
+		{
+			name:    "default labels",
+			groupBy: []string{"alertname", "deployment"},
+			alert:   a,
+			want:    "namespace=prod,alertname=HighLatency,deployment=api",
+		},
+		{
+			name:    "namespace label is not repeated",
+			groupBy: []string{"namespace", "alertname"},
+			alert:   a,
+			want:    "namespace=prod,alertname=HighLatency",
+		},
+		{
+			name:    "extra label",
+			groupBy: []string{"team"},
+			alert:   a,
+			want:    "namespace=prod,team=core",
+		},
+		{
+			name:    "missing label keeps its place",
+			groupBy: []string{"alertname", "severity"},
+			alert:   a,
+			want:    "namespace=prod,alertname=HighLatency,severity=",
+		},
+		{
+			name:    "deployment label used without resolved deployment",
+			groupBy: []string{"deployment"},
+			alert: func() Alert {
+				b := groupAlert(StatusFiring, "api-1")
+				b.Deployment = ""
+				b.Labels.Set("deployment", "from-label")
+				return b
+			}(),
+			want: "namespace=prod,deployment=from-label",
+		},
+		{
+			name:    "pods of one deployment share the key",
+			groupBy: []string{"alertname", "deployment"},
+			alert:   groupAlert(StatusFiring, "api-2"),
+			want:    "namespace=prod,alertname=HighLatency,deployment=api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := AlertGrouping{GroupBy: tt.groupBy, Window: time.Minute}
+			if got := g.Key(tt.alert); got != tt.want {
+				t.Errorf("Key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeGroup(t *testing.T) {
+	acked := groupAlert(StatusFiring, "api-3")
+	acked.AckedAt, acked.AckedByName = time.Now(), "alice"
+
+	tests := []struct {
+		name     string
+		alerts   []Alert
+		firing   int
+		resolved int
+		order    []string
+		contains []string
+	}{
+		{
+			name:   "empty group",
+			alerts: nil,
+		},
+		{
+			name:     "firing alerts first",
+			alerts:   []Alert{groupAlert(StatusResolved, "api-1"), groupAlert(StatusFiring, "api-2"), acked},
+			firing:   2,
+			resolved: 1,
+			order:    []string{"api-2", "api-3", "api-1"},
+			contains: []string{"Alert: HighLatency🚨", "Deployment: api", "Problem: p99 above 1s",
+				"Pods: 2 firing, 1 resolved", "🚨 api-3 — Ack: alice", "✅ api-1"},
+		},
+		{
+			name:     "all resolved",
+			alerts:   []Alert{groupAlert(StatusResolved, "api-1"), groupAlert(StatusResolved, "api-2")},
+			resolved: 2,
+			order:    []string{"api-1", "api-2"},
+			contains: []string{"Resolved: HighLatency✅", "Pods: 0 firing, 2 resolved"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SummarizeGroup(tt.alerts)
+			if s.Firing != tt.firing || s.Resolved != tt.resolved {
+				t.Errorf("firing/resolved = %d/%d, want %d/%d", s.Firing, s.Resolved, tt.firing, tt.resolved)
+			}
+			if s.IsResolved() != (tt.firing == 0) {
+				t.Errorf("IsResolved() = %v", s.IsResolved())
+			}
+
+			var order []string
+			for _, a := range s.Alerts {
+				order = append(order, a.Labels.Pod)
+			}
+			if strings.Join(order, ",") != strings.Join(tt.order, ",") {
+				t.Errorf("order = %v, want %v", order, tt.order)
+			}
+
+			text := s.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("String() = %q, missing %q", text, want)
+				}
+			}
+		})
+	}
+}
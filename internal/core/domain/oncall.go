@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"time"
+)
+
+const OnCallWeek = 7 * 24 * time.Hour
+
+// OnCallSchedule ротация дежурных namespace. Start — момент передачи смены первому
+// пользователю из UserIDs, дальше смена переходит к следующему каждые Period.
+// Период из целых суток отсчитывается по календарю в Location, чтобы смена
+// не сдвигалась на час при переходе на летнее время
+type OnCallSchedule struct {
+	Namespace string
+	UserIDs   []int64
+	Start     time.Time
+	Period    time.Duration
+	// Location временная зона расписания, nil — локальная зона сервера
+	Location *time.Location
+}
+
+// Zone возвращает временную зону, в которой считаются смены
+func (s OnCallSchedule) Zone() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// handover возвращает начало смены n
+func (s OnCallSchedule) handover(n int64) time.Time {
+	const day = 24 * time.Hour
+	if s.Period%day != 0 {
+		return s.Start.Add(time.Duration(n) * s.Period)
+	}
+	return s.Start.In(s.Zone()).AddDate(0, 0, int(n)*int(s.Period/day))
+}
+
+// OnCallOverride временная замена дежурного
+type OnCallOverride struct {
+	ID        int64
+	Namespace string
+	UserID    int64
+	From      time.Time
+	To        time.Time
+}
+
+func (o OnCallOverride) Active(at time.Time) bool {
+	return !at.Before(o.From) && at.Before(o.To)
+}
+
+// shift возвращает номер смены, в которую попадает момент at
+func (s OnCallSchedule) shift(at time.Time) int64 {
+	n := int64(at.Sub(s.Start) / s.Period)
+	if at.Before(s.Start) && at.Sub(s.Start)%s.Period != 0 {
+		n--
+	}
+	// Календарная смена может отличаться от Period на час перехода на летнее время
+	for at.Before(s.handover(n)) {
+		n--
+	}
+	for !at.Before(s.handover(n + 1)) {
+		n++
+	}
+	return n
+}
+
+// OnDuty возвращает дежурного по расписанию в момент at
+func (s OnCallSchedule) OnDuty(at time.Time) (int64, bool) {
+	if len(s.UserIDs) == 0 || s.Period <= 0 {
+		return 0, false
+	}
+
+	count := int64(len(s.UserIDs))
+	idx := (s.shift(at)%count + count) % count
+	return s.UserIDs[idx], true
+}
+
+// NextHandover возвращает время следующей передачи смены после at
+func (s OnCallSchedule) NextHandover(at time.Time) time.Time {
+	return s.handover(s.shift(at) + 1)
+}
+
+// CurrentOnCall учитывает замены: последняя созданная активная замена важнее расписания
+func CurrentOnCall(schedule *OnCallSchedule, overrides []OnCallOverride, at time.Time) (int64, bool) {
+	for i := len(overrides) - 1; i >= 0; i-- {
+		if overrides[i].Active(at) {
+			return overrides[i].UserID, true
+		}
+	}
+	if schedule == nil {
+		return 0, false
+	}
+	return schedule.OnDuty(at)
+}
+
+// LastHandover возвращает последний момент в прошлом, приходящийся на weekday и время hour:minute
+func LastHandover(now time.Time, weekday time.Weekday, hour, minute int) time.Time {
+	t := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	t = t.AddDate(0, 0, int(weekday)-int(t.Weekday()))
+	if t.After(now) {
+		t = t.AddDate(0, 0, -7)
+	}
+	return t
+}
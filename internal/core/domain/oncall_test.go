@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnCallScheduleAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	// Смены по понедельникам в 10:00, летнее время начинается 30 марта 2025
+	s := OnCallSchedule{
+		UserIDs:  []int64{1, 2},
+		Start:    time.Date(2025, time.March, 17, 10, 0, 0, 0, berlin),
+		Period:   OnCallWeek,
+		Location: berlin,
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		want     int64
+		handover time.Time
+	}{
+		{
+			name:     "before DST",
+			at:       time.Date(2025, time.March, 24, 9, 59, 0, 0, berlin),
+			want:     1,
+			handover: time.Date(2025, time.March, 24, 10, 0, 0, 0, berlin),
+		},
+		{
+			name:     "handover before DST",
+			at:       time.Date(2025, time.March, 24, 10, 0, 0, 0, berlin),
+			want:     2,
+			handover: time.Date(2025, time.March, 31, 10, 0, 0, 0, berlin),
+		},
+		{
+			name:     "hour before handover after DST",
+			at:       time.Date(2025, time.March, 31, 9, 30, 0, 0, berlin),
+			want:     2,
+			handover: time.Date(2025, time.March, 31, 10, 0, 0, 0, berlin),
+		},
+		{
+			name:     "handover after DST",
+			at:       time.Date(2025, time.March, 31, 10, 0, 0, 0, berlin),
+			want:     1,
+			handover: time.Date(2025, time.April, 7, 10, 0, 0, 0, berlin),
+		},
+		{
+			name:     "before start",
+			at:       time.Date(2025, time.March, 10, 10, 0, 0, 0, berlin),
+			want:     2,
+			handover: time.Date(2025, time.March, 17, 10, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := s.OnDuty(tt.at); !ok || got != tt.want {
+				t.Errorf("OnDuty() = %d, %v, want %d", got, ok, tt.want)
+			}
+			if got := s.NextHandover(tt.at); !got.Equal(tt.handover) {
+				t.Errorf("NextHandover() = %s, want %s", got, tt.handover)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// QueuedAlert алерт из вебхука, ожидающий обработки в очереди
+type QueuedAlert struct {
+	ID    int64
+	Alert Alert
+	// AlertID не ноль, если алерт уже записан в хранилище и осталось только разослать уведомления
+	AlertID   int64
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// RetentionPolicy определяет, сколько алертов хранить. Нулевое значение отключает правило
+type RetentionPolicy struct {
+	// MaxAge алерты, которые не обновлялись дольше этого срока, удаляются
+	MaxAge time.Duration
+	// MaxPerNamespace сколько последних алертов оставлять в каждом namespace
+	MaxPerNamespace int
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxPerNamespace > 0
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Silence заглушает алерты, у которых совпадают все метки из Matchers, до ExpiresAt.
+// Кроме меток алерта можно матчить namespace и deployment, определенные ботом.
+type Silence struct {
+	ID            int64
+	Matchers      map[string]string
+	CreatedBy     int64
+	CreatedByName string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+func (s Silence) Active(at time.Time) bool {
+	return at.Before(s.ExpiresAt)
+}
+
+func (s Silence) Matches(a Alert) bool {
+	if len(s.Matchers) == 0 {
+		return false
+	}
+
+	for name, value := range s.Matchers {
+		actual := a.Labels.Get(name)
+		switch {
+		case name == "namespace" && a.Namespace != "":
+			actual = a.Namespace
+		case name == "deployment" && actual == "":
+			actual = a.Deployment
+		}
+		if actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Silence) String() string {
+	matchers := make([]string, 0, len(s.Matchers))
+	for k, v := range s.Matchers {
+		matchers = append(matchers, k+"="+v)
+	}
+	sort.Strings(matchers)
+
+	return fmt.Sprintf("#%d %s до %s (%s)", s.ID, strings.Join(matchers, " "),
+		s.ExpiresAt.Local().Format("02.01 15:04"), s.CreatedByName)
+}
+
+// FindSilence возвращает первый активный silence, под который попадает алерт
+func FindSilence(silences []Silence, a Alert, at time.Time) (Silence, bool) {
+	for _, s := range silences {
+		if s.Active(at) && s.Matches(a) {
+			return s, true
+		}
+	}
+	return Silence{}, false
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	StatsByNamespace  = "namespace"
+	StatsByDeployment = "deployment"
+)
+
+type AlertCount struct {
+	Alertname string `json:"alertname"`
+	Count     int    `json:"count"`
+}
+
+// IncidentStats статистика инцидентов namespace или deployment за период
+type IncidentStats struct {
+	Namespace  string       `json:"namespace"`
+	Deployment string       `json:"deployment,omitempty"`
+	Incidents  int          `json:"incidents"`
+	Firing     int          `json:"firing"`
+	Acked      int          `json:"acked"`
+	Resolved   int          `json:"resolved"`
+	TopAlerts  []AlertCount `json:"topAlerts"`
+	// MTTA среднее время до подтверждения, MTTR — до разрешения
+	MTTA time.Duration `json:"-"`
+	MTTR time.Duration `json:"-"`
+}
+
+func (s IncidentStats) String() string {
+	name := s.Namespace
+	if s.Deployment != "" {
+		name += "/" + s.Deployment
+	}
+
+	top := make([]string, len(s.TopAlerts))
+	for i, a := range s.TopAlerts {
+		top[i] = fmt.Sprintf("%s ×%d", a.Alertname, a.Count)
+	}
+
+	res := fmt.Sprintf("%s: %d инцидентов (активно %d, решено %d)\n\tMTTA: %s, MTTR: %s",
+		name, s.Incidents, s.Firing, s.Resolved, formatMean(s.MTTA, s.Acked), formatMean(s.MTTR, s.Resolved))
+	if len(top) > 0 {
+		res += "\n\tЧаще всего: " + strings.Join(top, ", ")
+	}
+	return res
+}
+
+func formatMean(d time.Duration, n int) string {
+	if n == 0 {
+		return "—"
+	}
+	return d.Round(time.Second).String()
+}
+
+// CalculateStats группирует алерты по namespace или по namespace и deployment.
+// Заглушенные алерты не учитываются, группы упорядочены по числу инцидентов
+func CalculateStats(alerts []Alert, groupBy string, topN int) []IncidentStats {
+	type acc struct {
+		stats      IncidentStats
+		ackSum     time.Duration
+		resolveSum time.Duration
+		resolvedN  int
+		names      map[string]int
+	}
+
+	groups := map[[2]string]*acc{}
+	var order [][2]string
+	for _, a := range alerts {
+		if a.Suppressed {
+			continue
+		}
+
+		key := [2]string{a.Namespace, ""}
+		if groupBy == StatsByDeployment {
+			key[1] = a.Deployment
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &acc{stats: IncidentStats{Namespace: key[0], Deployment: key[1]}, names: map[string]int{}}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		g.stats.Incidents++
+		g.names[a.Labels.Alertname]++
+		if a.IsResolved() {
+			g.stats.Resolved++
+			if !a.StartsAt.IsZero() && a.EndsAt.After(a.StartsAt) {
+				g.resolveSum += a.EndsAt.Sub(a.StartsAt)
+				g.resolvedN++
+			}
+		} else {
+			g.stats.Firing++
+		}
+		if a.IsAcked() && !a.StartsAt.IsZero() && a.AckedAt.After(a.StartsAt) {
+			g.stats.Acked++
+			g.ackSum += a.AckedAt.Sub(a.StartsAt)
+		}
+	}
+
+	res := make([]IncidentStats, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		if g.stats.Acked > 0 {
+			g.stats.MTTA = g.ackSum / time.Duration(g.stats.Acked)
+		}
+		if g.resolvedN > 0 {
+			g.stats.MTTR = g.resolveSum / time.Duration(g.resolvedN)
+		}
+		g.stats.TopAlerts = topAlertnames(g.names, topN)
+		res = append(res, g.stats)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Incidents > res[j].Incidents })
+	return res
+}
+
+func topAlertnames(names map[string]int, n int) []AlertCount {
+	res := make([]AlertCount, 0, len(names))
+	for name, count := range names {
+		res = append(res, AlertCount{Alertname: name, Count: count})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Alertname < res[j].Alertname
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
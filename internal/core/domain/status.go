@@ -11,8 +11,12 @@ type PodStatus struct {
 	TotalMem   float64
 }
 
+// DeploymentAvailable статус deployment, у которого доступны все реплики
+const DeploymentAvailable = "Available"
+
 type DeployStatus struct {
-	Name   string
-	Status string
-	Pods   map[string]PodStatus
+	Name      string
+	Namespace string
+	Status    string
+	Pods      map[string]PodStatus
 }
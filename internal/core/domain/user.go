@@ -0,0 +1,46 @@
+package domain
+
+import "strings"
+
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleNone:     "none",
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return roleNames[RoleNone]
+}
+
+func ParseRole(s string) (Role, bool) {
+	for role, name := range roleNames {
+		if role != RoleNone && strings.EqualFold(s, name) {
+			return role, true
+		}
+	}
+	return RoleNone, false
+}
+
+// User пользователь Telegram, которому разрешен доступ к боту
+type User struct {
+	ID   int64
+	Name string
+	Role Role
+}
+
+func (u User) Can(need Role) bool {
+	return u.Role >= need
+}
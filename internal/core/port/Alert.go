@@ -2,9 +2,24 @@ package port
 
 import (
 	"hack-a-tone/internal/core/domain"
+	"time"
 )
 
 type AlertRepo interface {
 	GetLastNAlerts(n int, namespaces []string) ([]domain.Alert, error)
-	WriteAlert(alert domain.Alert, namespace string) error
+	// FindAlerts возвращает алерты по фильтру, начиная с новых
+	FindAlerts(filter domain.AlertFilter) ([]domain.Alert, error)
+	WriteAlert(alert domain.Alert, namespace string) (int64, error)
+	GetAlert(id int64) (*domain.Alert, error)
+	GetOpenAlert(fingerprint string) (*domain.Alert, error)
+	UpdateAlert(alert domain.Alert) error
+	AckAlert(id int64, userID int64, userName string, at time.Time) error
+	GetUnackedAlerts() ([]domain.Alert, error)
+	SetEscalation(id int64, level int, at time.Time) error
+	AddAlertMessage(msg domain.AlertMessage) error
+	GetAlertMessages(alertID int64) ([]domain.AlertMessage, error)
+	// WriteGroupedAlert записывает алерт в группу alert.GroupKey, открытую не раньше now-window, или открывает
+	// новую группу. Возвращает ID алерта и ID первого алерта группы
+	WriteGroupedAlert(alert domain.Alert, namespace string, window time.Duration, now time.Time) (int64, int64, error)
+	GetGroupAlerts(leaderID int64) ([]domain.Alert, error)
 }
@@ -0,0 +1,8 @@
+package port
+
+import "hack-a-tone/internal/core/domain"
+
+type AuditRepo interface {
+	WriteAudit(entry domain.AuditEntry) error
+	GetLastNAudit(n int, namespace string) ([]domain.AuditEntry, error)
+}
@@ -0,0 +1,15 @@
+package port
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"time"
+)
+
+type DigestRepo interface {
+	GetDigestSchedules() ([]domain.DigestSchedule, error)
+	// GetDigestSchedule возвращает расписание сводки чата или nil, если его нет
+	GetDigestSchedule(chatID int64) (*domain.DigestSchedule, error)
+	SaveDigestSchedule(schedule domain.DigestSchedule) error
+	DeleteDigestSchedule(chatID int64) error
+	MarkDigestSent(chatID int64, at time.Time) error
+}
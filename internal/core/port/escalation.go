@@ -0,0 +1,9 @@
+package port
+
+import "hack-a-tone/internal/core/domain"
+
+type EscalationRepo interface {
+	GetEscalationPolicies() ([]domain.EscalationPolicy, error)
+	SaveEscalationPolicy(policy domain.EscalationPolicy) error
+	DeleteEscalationPolicy(namespace string) error
+}
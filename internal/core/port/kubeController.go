@@ -9,6 +9,7 @@ import (
 
 type KubeController interface {
 	GetAllPods(ctx context.Context, nameSpace string) (*corev1.PodList, error)
+	GetPod(ctx context.Context, nameSpace, podName string) (*corev1.Pod, error)
 	GetNamespaceFromPod(ctx context.Context, podName string) (string, error)
 	GetDeploymentFromPod(ctx context.Context, pod *corev1.Pod) (string, error)
 	GetDeployments(ctx context.Context, nameSpace string) (*v1.DeploymentList, error)
@@ -0,0 +1,13 @@
+package port
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"time"
+)
+
+type OnCallRepo interface {
+	GetOnCallSchedule(namespace string) (*domain.OnCallSchedule, error)
+	SaveOnCallSchedule(schedule domain.OnCallSchedule) error
+	AddOnCallOverride(override domain.OnCallOverride) error
+	GetOnCallOverrides(namespace string, at time.Time) ([]domain.OnCallOverride, error)
+}
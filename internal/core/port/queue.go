@@ -0,0 +1,23 @@
+package port
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"time"
+)
+
+type QueueRepo interface {
+	// EnqueueAlerts сохраняет алерты в очередь одной транзакцией
+	EnqueueAlerts(alerts []domain.Alert, at time.Time) error
+	// ClaimQueuedAlerts забирает готовые к обработке алерты и блокирует их на время lease.
+	// Каждый захват увеличивает счетчик попыток
+	ClaimQueuedAlerts(now time.Time, lease time.Duration, limit int) ([]domain.QueuedAlert, error)
+	SetQueuedAlertID(id, alertID int64) error
+	// FindQueuedAlert возвращает ID алерта, который записала строка очереди id, или 0
+	FindQueuedAlert(id int64) (int64, error)
+	CompleteQueuedAlert(id int64) error
+	RetryQueuedAlert(id int64, next time.Time, lastErr string) error
+	// FailQueuedAlert оставляет алерт в очереди с ошибкой, больше он не обрабатывается
+	FailQueuedAlert(id int64, lastErr string) error
+	// DeleteFailedQueuedAlerts удаляет необработанные алерты, поставленные в очередь до before
+	DeleteFailedQueuedAlerts(before time.Time) (int64, error)
+}
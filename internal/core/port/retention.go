@@ -0,0 +1,13 @@
+package port
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"time"
+)
+
+type RetentionRepo interface {
+	// GetExpiredAlerts возвращает до limit алертов, которые нужно удалить по политике, начиная со старых
+	GetExpiredAlerts(policy domain.RetentionPolicy, now time.Time, limit int) ([]domain.Alert, error)
+	// DeleteAlerts удаляет алерты вместе с сохраненными сообщениями о них
+	DeleteAlerts(ids []int64) error
+}
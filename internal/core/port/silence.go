@@ -0,0 +1,12 @@
+package port
+
+import (
+	"hack-a-tone/internal/core/domain"
+	"time"
+)
+
+type SilenceRepo interface {
+	CreateSilence(silence domain.Silence) (int64, error)
+	GetActiveSilences(at time.Time) ([]domain.Silence, error)
+	ExpireSilence(id int64, at time.Time) error
+}
@@ -0,0 +1,7 @@
+package port
+
+type SubscriptionRepo interface {
+	GetSubscriptions() (map[int64][]string, error)
+	AddNamespaces(chatID int64, namespaces []string) error
+	RemoveNamespaces(chatID int64, namespaces []string) error
+}
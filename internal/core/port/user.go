@@ -0,0 +1,10 @@
+package port
+
+import "hack-a-tone/internal/core/domain"
+
+type UserRepo interface {
+	GetUser(id int64) (*domain.User, error)
+	SaveUser(user domain.User) error
+	DeleteUser(id int64) error
+	ListUsers() ([]domain.User, error)
+}